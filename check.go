@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/palantir/tfjson/tfjson"
+)
+
+// exitViolations is the exit code of "tfjson check" if any plan violates the
+// policy.
+const exitViolations = 2
+
+// runCheck implements "tfjson check", which evaluates a policy against the
+// changes planned by one or more plan files.
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	policyFile := flags.String("policy", "", "file containing the policy rules, in HCL or JSON")
+	text := flags.Bool("text", false, "emit a human-readable report instead of JSON")
+	showSensitive := flags.Bool("show-sensitive", false, "let conditions read sensitive attribute values")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson check -policy rules.hcl [flags] terraform.tfplan...")
+		fmt.Fprintln(os.Stderr, "A plan file of - is read from standard input.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *policyFile == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	policy, err := readPolicy(*policyFile)
+	if err != nil {
+		fatal(err)
+	}
+
+	violations := []*tfjson.Violation{}
+	for _, planfile := range flags.Args() {
+		plan, err := readPlan(planfile)
+		if err != nil {
+			fatal(err)
+		}
+		v, err := policy.Evaluate(plan.Diff, tfjson.Options{ShowSensitive: *showSensitive})
+		if err != nil {
+			fatal(fmt.Errorf("%s: %s", planfile, err))
+		}
+		violations = append(violations, v...)
+	}
+
+	if *text {
+		printViolations(os.Stdout, violations)
+	} else {
+		printJSON(violations, nil)
+	}
+
+	if len(violations) > 0 {
+		os.Exit(exitViolations)
+	}
+}
+
+// readPolicy reads the policy file at name.
+func readPolicy(name string) (*tfjson.Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	policy, err := tfjson.ReadPolicy(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return policy, nil
+}
+
+// printViolations writes a human-readable report of violations to w.
+func printViolations(w io.Writer, violations []*tfjson.Violation) {
+	if len(violations) == 0 {
+		fmt.Fprintln(w, "No policy violations.")
+		return
+	}
+
+	for _, v := range violations {
+		fmt.Fprintf(w, "%s: %s violates %s", v.Address, v.Action, v.Rule)
+		if len(v.Attributes) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(v.Attributes, ", "))
+		}
+		fmt.Fprintln(w)
+		if v.Description != "" {
+			fmt.Fprintf(w, "    %s\n", v.Description)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/palantir/tfjson/tfjson"
+)
+
+// runDiff implements "tfjson diff", which compares the changes planned by two
+// plan files. It exits with 0 if they plan the same changes and 2 if they
+// differ.
+func runDiff(args []string) {
+	var opts tfjson.Options
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.BoolVar(&opts.ShowSensitive, "show-sensitive", false, "emit the values of sensitive attributes instead of redacting them")
+	text := flags.Bool("text", false, "emit a human-readable report instead of JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson diff [flags] old.tfplan new.tfplan")
+		fmt.Fprintln(os.Stderr, "A plan file of - is read from standard input.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	old, err := readPlan(flags.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	new, err := readPlan(flags.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+
+	c := tfjson.ComparePlans(old, new, opts)
+	if *text {
+		printComparison(os.Stdout, c)
+	} else {
+		printJSON(c, nil)
+	}
+
+	if !c.Equal {
+		os.Exit(exitChanges)
+	}
+}
+
+// runStateDiff implements "tfjson state-diff", which compares two state
+// files. It exits with 0 if they are equal and 2 if they differ.
+func runStateDiff(args []string) {
+	flags := flag.NewFlagSet("state-diff", flag.ExitOnError)
+	text := flags.Bool("text", false, "emit a human-readable report instead of JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson state-diff [flags] old.tfstate new.tfstate")
+		fmt.Fprintln(os.Stderr, "A state file of - is read from standard input.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	old, err := readRawState(flags.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	new, err := readRawState(flags.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+
+	c := tfjson.CompareStates(old, new)
+	for _, w := range c.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+	if *text {
+		printStateComparison(os.Stdout, c)
+	} else {
+		printJSON(c, nil)
+	}
+
+	if !c.Equal {
+		os.Exit(exitChanges)
+	}
+}
+
+// printComparison writes a human-readable report of c to w.
+func printComparison(w io.Writer, c *tfjson.Comparison) {
+	if c.Equal {
+		fmt.Fprintln(w, "Plans are equal.")
+		return
+	}
+
+	for _, r := range c.Resources {
+		addr := displayAddress(r.Path, r.Name)
+		switch {
+		case r.OldAction == "":
+			fmt.Fprintf(w, "+ %s: only in new plan (%s)\n", addr, r.NewAction)
+		case r.NewAction == "":
+			fmt.Fprintf(w, "- %s: only in old plan (%s)\n", addr, r.OldAction)
+		case r.OldAction != r.NewAction:
+			fmt.Fprintf(w, "~ %s: %s -> %s\n", addr, r.OldAction, r.NewAction)
+		default:
+			fmt.Fprintf(w, "~ %s: %s\n", addr, r.NewAction)
+		}
+		for _, a := range r.Attributes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", a.Name, displayNew(a.Old), displayNew(a.New))
+		}
+	}
+}
+
+// displayAddress returns the canonical address of the resource instance name
+// in the module at path, or name itself if it is not a valid key.
+func displayAddress(path []string, name string) string {
+	addr, err := tfjson.InstanceAddress(path, name)
+	if err != nil {
+		return name
+	}
+	return addr.String()
+}
+
+// displayOutputAddress returns the address of the output name in the module
+// at path, such as "module.a.output.ip".
+func displayOutputAddress(path []string, name string) string {
+	if len(path) > 0 && path[0] == "root" {
+		path = path[1:]
+	}
+	var parts []string
+	for _, p := range path {
+		parts = append(parts, "module."+p)
+	}
+	return strings.Join(append(parts, "output."+name), ".")
+}
+
+// displayNew returns a short description of the new value of diff.
+func displayNew(diff *tfjson.AttributeDiff) string {
+	switch {
+	case diff == nil:
+		return "(absent)"
+	case diff.NewComputed:
+		return "<computed>"
+	case diff.NewRemoved:
+		return "(removed)"
+	case diff.RequiresNew:
+		return fmt.Sprintf("%q (forces new resource)", diff.New)
+	default:
+		return fmt.Sprintf("%q", diff.New)
+	}
+}
+
+// printStateComparison writes a human-readable report of c to w.
+func printStateComparison(w io.Writer, c *tfjson.StateComparison) {
+	if c.Equal {
+		fmt.Fprintln(w, "States are equal.")
+		return
+	}
+
+	symbols := map[string]string{"added": "+", "removed": "-", "changed": "~"}
+	for _, r := range c.Resources {
+		fmt.Fprintf(w, "%s %s: %s\n", symbols[r.Change], displayAddress(r.Path, r.Name), r.Change)
+		for _, a := range r.Attributes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", a.Name, displayValue(a.Old), displayValue(a.New))
+		}
+	}
+	for _, o := range c.Outputs {
+		fmt.Fprintf(w, "%s %s: %s\n", symbols[o.Change], displayOutputAddress(o.Path, o.Name), o.Change)
+		fmt.Fprintf(w, "    %s -> %s\n", displayOutput(o.Old), displayOutput(o.New))
+	}
+}
+
+// displayOutput returns a short description of the value of an output in a
+// state, or of its absence if v is nil.
+func displayOutput(v interface{}) string {
+	if v == nil {
+		return "(absent)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// displayValue returns a short description of an attribute value in a
+// state, or of its absence if v is nil.
+func displayValue(v *string) string {
+	if v == nil {
+		return "(absent)"
+	}
+	return fmt.Sprintf("%q", *v)
+}
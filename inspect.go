@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/palantir/tfjson/tfjson"
+)
+
+// runInspect implements "tfjson inspect", which describes the format of plan
+// files without converting them.
+func runInspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson inspect terraform.tfplan...")
+		fmt.Fprintln(os.Stderr, "A plan file of - is read from standard input.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	out := make(map[string]*tfjson.PlanFile)
+	for _, planfile := range flags.Args() {
+		info, err := inspect(planfile)
+		if err != nil {
+			fatal(err)
+		}
+		out[planfile] = info
+	}
+	printJSON(out, nil)
+}
+
+// inspect describes the format of the plan file at planfile, or standard
+// input if planfile is "-".
+func inspect(planfile string) (*tfjson.PlanFile, error) {
+	r, err := open(planfile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", planfile, err)
+	}
+	return tfjson.InspectPlan(b), nil
+}
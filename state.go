@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmespath/go-jmespath"
+	"github.com/palantir/tfjson/tfjson"
+)
+
+// runState implements "tfjson state", which converts state files.
+func runState(args []string) {
+	var opts tfjson.Options
+	flags := flag.NewFlagSet("state", flag.ExitOnError)
+	flags.BoolVar(&opts.ShowSensitive, "show-sensitive", false, "emit the values of sensitive outputs instead of redacting them")
+	flags.BoolVar(&opts.Expand, "expand", false, "expand flattened attribute keys into nested maps and lists")
+	query := flags.String("query", "", "JMESPath expression to evaluate against the output, printing only its result")
+	stream := flags.Bool("stream", false, "emit one JSON document per state instead of an object keyed by file name")
+	keyed := flags.Bool("keyed", false, "emit an object keyed by file name even if only one state file is given")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson state [flags] terraform.tfstate...")
+		fmt.Fprintln(os.Stderr, "A state file of - is read from standard input.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	var expr *jmespath.JMESPath
+	if *query != "" {
+		var err error
+		if expr, err = jmespath.Compile(*query); err != nil {
+			fatal(fmt.Errorf("invalid -query: %s", err))
+		}
+	}
+
+	docs := make([]interface{}, flags.NArg())
+	for i, statefile := range flags.Args() {
+		state, err := readState(statefile, opts)
+		if err != nil {
+			fatal(err)
+		}
+		docs[i] = state
+	}
+	for _, doc := range documents(flags.Args(), docs, *stream, *keyed) {
+		printJSON(doc, expr)
+	}
+}
+
+// readState reads and converts the state file at statefile, or standard
+// input if statefile is "-".
+func readState(statefile string, opts tfjson.Options) (*tfjson.StateFile, error) {
+	r, err := open(statefile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	state, err := tfjson.ReadState(r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", statefile, err)
+	}
+	return state, nil
+}
+
+// readRawState reads the state file at statefile, or standard input if
+// statefile is "-".
+func readRawState(statefile string) (*terraform.State, error) {
+	r, err := open(statefile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	state, err := tfjson.ReadRawState(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", statefile, err)
+	}
+	return state, nil
+}
@@ -23,48 +23,298 @@ SOFTWARE.
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmespath/go-jmespath"
+	"github.com/palantir/tfjson/tfjson"
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintln(os.Stderr, "usage: tfjson terraform.tfplan")
-		os.Exit(1)
+	// The vendored terraform packages log to the standard logger
+	log.SetOutput(ioutil.Discard)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "state":
+			runState(os.Args[2:])
+			return
+		case "state-diff":
+			runStateDiff(os.Args[2:])
+			return
+		case "inspect":
+			runInspect(os.Args[2:])
+			return
+		}
 	}
 
-	j, err := tfjson(os.Args[1])
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	var opts options
+	flag.BoolVar(&opts.Detailed, "detailed", false, "emit each attribute as an object with its old and new values and flags")
+	flag.BoolVar(&opts.ShowSensitive, "show-sensitive", false, "emit the values of sensitive attributes instead of redacting them")
+	flag.BoolVar(&opts.Expand, "expand", false, "expand flattened attribute keys into nested maps and lists")
+	flag.BoolVar(&opts.State, "state", false, "include the state the plan was created against")
+	flag.BoolVar(&opts.Variables, "variables", false, "include the variables the plan was created with")
+	redactVars := flag.String("redact-vars", strings.Join(tfjson.DefaultRedactVariables, ","), "comma-separated patterns for the names of variables whose values are redacted")
+	flag.BoolVar(&opts.Targets, "targets", false, "include the resource addresses the plan was targeted at")
+	flag.BoolVar(&opts.Config, "config", false, "include the configuration of every module")
+	filters := flag.String("filter", "", "comma-separated resource address patterns, such as module.network.aws_subnet.* or aws_iam_*, to restrict the output to")
+	where := flag.String("where", "", "HIL condition, such as ${eq(action, \"replace\")}, restricting the output to resources for which it holds")
+	flag.BoolVar(&opts.legacy, "legacy", false, "emit the unversioned nested output of earlier releases")
+	flag.BoolVar(&opts.flat, "flat", false, "emit resources keyed by their canonical addresses, such as module.network.aws_subnet.a[0]")
+	flag.BoolVar(&opts.group, "group", false, "group the instances of resources with a count, such as aws_instance.web.0, under their resource")
+	flag.BoolVar(&opts.showJSON, "show-json", false, "emit the JSON plan representation of \"terraform show -json\" in later Terraform releases")
+	flag.BoolVar(&opts.summary, "summary", false, "emit only the number of resources to add, change, replace and destroy")
+	query := flag.String("query", "", "JMESPath expression to evaluate against the output, printing only its result")
+	stream := flag.Bool("stream", false, "emit one JSON document per plan instead of an object keyed by file name")
+	keyed := flag.Bool("keyed", false, "emit an object keyed by file name even if only one plan file is given")
+	detailedExitCode := flag.Bool("detailed-exitcode", false, "exit with 0 if no plan has changes, 2 if any has changes and 3 if any destroys or replaces resources")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tfjson [flags] terraform.tfplan...")
+		fmt.Fprintln(os.Stderr, "       tfjson diff [flags] old.tfplan new.tfplan")
+		fmt.Fprintln(os.Stderr, "       tfjson check -policy rules.hcl [flags] terraform.tfplan...")
+		fmt.Fprintln(os.Stderr, "       tfjson state [flags] terraform.tfstate...")
+		fmt.Fprintln(os.Stderr, "       tfjson state-diff [flags] old.tfstate new.tfstate")
+		fmt.Fprintln(os.Stderr, "       tfjson inspect terraform.tfplan...")
+		fmt.Fprintln(os.Stderr, "A plan file of - is read from standard input.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String() != "false"
+	})
+	if err := checkModes(set); err != nil {
+		fatal(err)
+	}
+
+	opts.RedactVariables = []string{}
+	if *redactVars != "" {
+		opts.RedactVariables = strings.Split(*redactVars, ",")
+	}
+	for _, pattern := range opts.RedactVariables {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fatal(fmt.Errorf("invalid -redact-vars pattern %q: %s", pattern, err))
+		}
+	}
+
+	var addrs []*terraform.ResourceAddress
+	if *filters != "" {
+		for _, filter := range strings.Split(*filters, ",") {
+			addr, err := tfjson.ParseFilter(filter)
+			if err != nil {
+				fatal(fmt.Errorf("invalid -filter: %s", err))
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+
+	var cond *tfjson.Condition
+	if *where != "" {
+		var err error
+		if cond, err = tfjson.ParseCondition(*where); err != nil {
+			fatal(fmt.Errorf("invalid -where: %s", err))
+		}
+	}
+
+	var expr *jmespath.JMESPath
+	if *query != "" {
+		var err error
+		if expr, err = jmespath.Compile(*query); err != nil {
+			fatal(fmt.Errorf("invalid -query: %s", err))
+		}
+	}
+
+	plans := make([]*terraform.Plan, flag.NArg())
+	for i, planfile := range flag.Args() {
+		plan, err := readPlan(planfile)
+		if err != nil {
+			fatal(err)
+		}
+		if addrs != nil {
+			plan.Diff = tfjson.FilterDiff(plan.Diff, addrs)
+		}
+		if cond != nil {
+			if plan.Diff, err = tfjson.FilterDiffCondition(plan.Diff, cond, opts.Options); err != nil {
+				fatal(fmt.Errorf("%s: %s", planfile, err))
+			}
+		}
+		plans[i] = plan
+	}
+
+	docs := make([]interface{}, len(plans))
+	for i, plan := range plans {
+		docs[i] = convert(plan, opts)
+	}
+	for _, doc := range documents(flag.Args(), docs, *stream, *keyed) {
+		printJSON(doc, expr)
 	}
 
-	fmt.Println(j)
+	if *detailedExitCode {
+		code := exitNoChanges
+		for _, plan := range plans {
+			if c := exitCode(plan.Diff); c > code {
+				code = c
+			}
+		}
+		os.Exit(code)
+	}
 }
 
-type output map[string]interface{}
+// Exit codes used with -detailed-exitcode. Higher codes take precedence when
+// several plans are given.
+const (
+	exitNoChanges = 0
+	exitError     = 1
+	exitChanges   = 2
+	exitDestroy   = 3
+)
 
-func tfjson(planfile string) (string, error) {
-	f, err := os.Open(planfile)
-	if err != nil {
-		return "", err
+// outputModes are the flags that select a representation other than
+// tfjson.Plan, and the options that apply to each of them.
+var outputModes = []struct {
+	name    string
+	options []string
+}{
+	{"summary", nil},
+	{"legacy", []string{"show-sensitive"}},
+	{"show-json", []string{"show-sensitive", "state", "variables", "redact-vars"}},
+	{"flat", []string{"detailed", "show-sensitive", "expand"}},
+	{"group", []string{"detailed", "show-sensitive", "expand"}},
+}
+
+// outputOptions are the flags that control the content of tfjson.Plan.
+var outputOptions = []string{"detailed", "show-sensitive", "expand", "state", "variables", "redact-vars", "targets", "config"}
+
+// checkModes returns an error if the flags in set select more than one output
+// mode, or give an option that the selected mode ignores.
+func checkModes(set map[string]bool) error {
+	var modes []string
+	var options []string
+	for _, m := range outputModes {
+		if set[m.name] {
+			modes = append(modes, "-"+m.name)
+			options = m.options
+		}
+	}
+	switch len(modes) {
+	case 0:
+		return nil
+	case 1:
+	default:
+		return fmt.Errorf("%s cannot be used together", strings.Join(modes, " and "))
+	}
+	for _, opt := range outputOptions {
+		if !set[opt] {
+			continue
+		}
+		applies := false
+		for _, o := range options {
+			applies = applies || o == opt
+		}
+		if !applies {
+			return fmt.Errorf("-%s does not apply to %s", opt, modes[0])
+		}
+	}
+	return nil
+}
+
+// documents returns the JSON documents to print for the converted plans or
+// states docs, read from the files names. They are printed as one document per
+// file if stream is set, and otherwise as a single object keyed by file name,
+// unless only one file is given and keyed is not set.
+func documents(names []string, docs []interface{}, stream, keyed bool) []interface{} {
+	if stream || (len(docs) == 1 && !keyed) {
+		return docs
 	}
-	defer f.Close()
+	out := make(map[string]interface{})
+	for i, doc := range docs {
+		out[names[i]] = doc
+	}
+	return []interface{}{out}
+}
+
+// exitCode returns the exit code used with -detailed-exitcode for diff.
+func exitCode(diff *terraform.Diff) int {
+	s := tfjson.Summarize(diff)
+	switch {
+	case s.Destroy > 0 || s.Replace > 0:
+		return exitDestroy
+	case !s.Empty:
+		return exitChanges
+	default:
+		return exitNoChanges
+	}
+}
 
-	plan, err := terraform.ReadPlan(f)
+// printJSON prints the JSON encoding of v, or of the result of expr against v
+// if expr is not nil.
+func printJSON(v interface{}, expr *jmespath.JMESPath) {
+	if expr != nil {
+		var err error
+		if v, err = tfjson.Query(expr, v); err != nil {
+			fatal(err)
+		}
+	}
+
+	j, err := tfjson.Marshal(v)
 	if err != nil {
-		return "", err
+		fatal(err)
 	}
+	fmt.Println(string(j))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(exitError)
+}
+
+// options controls how the command converts a plan.
+type options struct {
+	tfjson.Options
+
+	// legacy emits tfjson.Legacy instead of tfjson.Plan.
+	legacy bool
 
-	diff := output{}
-	for _, v := range plan.Diff.Modules {
-		convertModuleDiff(diff, v)
+	// summary emits tfjson.Summary instead of tfjson.Plan.
+	summary bool
+
+	// showJSON emits tfjson.JSONPlan instead of tfjson.Plan.
+	showJSON bool
+
+	// flat emits tfjson.Flat instead of tfjson.Plan.
+	flat bool
+
+	// group emits tfjson.Grouped instead of tfjson.Plan.
+	group bool
+}
+
+// convertFile converts the plan file at planfile to indented JSON.
+func convertFile(planfile string, opts options) (string, error) {
+	plan, err := readPlan(planfile)
+	if err != nil {
+		return "", err
 	}
 
-	j, err := json.MarshalIndent(diff, "", "    ")
+	j, err := tfjson.Marshal(convert(plan, opts))
 	if err != nil {
 		return "", err
 	}
@@ -72,34 +322,44 @@ func tfjson(planfile string) (string, error) {
 	return string(j), nil
 }
 
-func insert(out output, path []string, key string, value interface{}) {
-	if len(path) > 0 && path[0] == "root" {
-		path = path[1:]
-	}
-	for _, elem := range path {
-		switch nested := out[elem].(type) {
-		case output:
-			out = nested
-		default:
-			new := output{}
-			out[elem] = new
-			out = new
-		}
+// readPlan reads the plan file at planfile, or standard input if planfile is
+// "-".
+func readPlan(planfile string) (*terraform.Plan, error) {
+	r, err := open(planfile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	plan, err := tfjson.ReadRawPlan(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", planfile, err)
 	}
-	out[key] = value
+	return plan, nil
 }
 
-func convertModuleDiff(out output, diff *terraform.ModuleDiff) {
-	insert(out, diff.Path, "destroy", diff.Destroy)
-	for k, v := range diff.Resources {
-		convertInstanceDiff(out, append(diff.Path, k), v)
+// open opens the file at name, or standard input if name is "-".
+func open(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
 	}
+	return os.Open(name)
 }
 
-func convertInstanceDiff(out output, path []string, diff *terraform.InstanceDiff) {
-	insert(out, path, "destroy", diff.Destroy)
-	insert(out, path, "destroy_tainted", diff.DestroyTainted)
-	for k, v := range diff.Attributes {
-		insert(out, path, k, v.New)
+// convert returns the representation of plan selected by opts.
+func convert(plan *terraform.Plan, opts options) interface{} {
+	switch {
+	case opts.summary:
+		return tfjson.Summarize(plan.Diff)
+	case opts.legacy:
+		return tfjson.ConvertLegacy(plan, opts.Options)
+	case opts.showJSON:
+		return tfjson.ConvertJSONPlan(plan, opts.Options)
+	case opts.flat:
+		return tfjson.ConvertFlat(plan, opts.Options)
+	case opts.group:
+		return tfjson.ConvertGrouped(plan, opts.Options)
+	default:
+		return tfjson.ConvertPlan(plan, opts.Options)
 	}
 }
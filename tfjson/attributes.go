@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// convertAttributes converts the attributes of an instance diff, expanding
+// them if requested.
+func convertAttributes(attrs map[string]*terraform.ResourceAttrDiff, opts Options) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range attrs {
+		result[k] = convertAttrDiff(v, opts)
+	}
+	if opts.Expand {
+		return expand(result, func(k string) bool {
+			return computed(attrs[k])
+		})
+	}
+	return result
+}
+
+// convertStateAttributes converts the attributes of an instance state,
+// redacting those in sensitive and expanding them if requested.
+func convertStateAttributes(attrs map[string]string, sensitive map[string]bool, opts Options) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range attrs {
+		if sensitive[k] && !opts.ShowSensitive {
+			result[k] = SensitivePlaceholder
+			continue
+		}
+		result[k] = v
+	}
+	if opts.Expand {
+		return expand(result, func(k string) bool {
+			return attrs[k] == config.UnknownVariableValue
+		})
+	}
+	return result
+}
+
+func convertAttrDiff(diff *terraform.ResourceAttrDiff, opts Options) interface{} {
+	old, new := diff.Old, diff.New
+	if diff.Sensitive && !opts.ShowSensitive {
+		old, new = SensitivePlaceholder, SensitivePlaceholder
+	}
+
+	if !opts.Detailed {
+		return new
+	}
+	return AttributeDiff{
+		Old:         old,
+		New:         new,
+		NewComputed: diff.NewComputed,
+		NewRemoved:  diff.NewRemoved,
+		RequiresNew: diff.RequiresNew,
+	}
+}
+
+// expand rebuilds flattened attribute keys into nested maps and lists. It
+// follows flatmap.Expand, but also copes with sets, whose elements are keyed
+// by hash rather than by position, and with counts that will not be known
+// until apply. unknown reports whether the count stored under a "#" or "%" key
+// is unknown, in which case the count value itself is emitted.
+func expand(attrs map[string]interface{}, unknown func(string) bool) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, k := range childKeys(attrs, "") {
+		result[k] = expandAttribute(attrs, k, unknown)
+	}
+	return result
+}
+
+func expandAttribute(attrs map[string]interface{}, key string, unknown func(string) bool) interface{} {
+	// If the key is exactly a key in the map, just return it
+	if v, ok := attrs[key]; ok {
+		return v
+	}
+
+	prefix := key + "."
+	if v, ok := attrs[prefix+"#"]; ok {
+		if unknown(prefix + "#") {
+			return v
+		}
+		indexes := childKeys(attrs, prefix)
+		sort.Sort(byIndex(indexes))
+		result := make([]interface{}, 0, len(indexes))
+		for _, i := range indexes {
+			result = append(result, expandAttribute(attrs, prefix+i, unknown))
+		}
+		return result
+	}
+
+	if v, ok := attrs[prefix+"%"]; ok && unknown(prefix+"%") {
+		return v
+	}
+	result := make(map[string]interface{})
+	for _, k := range childKeys(attrs, prefix) {
+		result[k] = expandAttribute(attrs, prefix+k, unknown)
+	}
+	return result
+}
+
+// childKeys returns the distinct first key segments that follow prefix in the
+// keys of attrs, excluding the "#" and "%" count markers.
+func childKeys(attrs map[string]interface{}, prefix string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for k := range attrs {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		key := k[len(prefix):]
+		if idx := strings.Index(key, "."); idx != -1 {
+			key = key[:idx]
+		}
+		if key == "#" || key == "%" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// computed returns true if the new value of diff will only be known after
+// apply.
+func computed(diff *terraform.ResourceAttrDiff) bool {
+	return diff.NewComputed || diff.New == config.UnknownVariableValue
+}
+
+// byIndex sorts list and set indexes numerically.
+type byIndex []string
+
+func (a byIndex) Len() int      { return len(a) }
+func (a byIndex) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byIndex) Less(i, j int) bool {
+	x, errX := strconv.Atoi(a[i])
+	y, errY := strconv.Atoi(a[j])
+	if errX != nil || errY != nil {
+		return a[i] < a[j]
+	}
+	return x < y
+}
@@ -0,0 +1,462 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Comparison describes how the changes planned by two plans differ.
+type Comparison struct {
+	// Equal is true if both plans make the same changes.
+	Equal     bool                `json:"equal"`
+	Resources []*ResourceMismatch `json:"resources"`
+}
+
+// ResourceMismatch is a resource instance whose planned changes differ
+// between two plans. OldAction and NewAction are empty if the instance is
+// missing from the old or new plan respectively.
+type ResourceMismatch struct {
+	Path       []string             `json:"path"`
+	Name       string               `json:"name"`
+	OldAction  string               `json:"old_action"`
+	NewAction  string               `json:"new_action"`
+	Attributes []*AttributeMismatch `json:"attributes"`
+}
+
+// AttributeMismatch is an attribute whose planned change differs between two
+// plans. Old and New are nil if the attribute is missing from the old or new
+// plan respectively.
+type AttributeMismatch struct {
+	Name string         `json:"name"`
+	Old  *AttributeDiff `json:"old"`
+	New  *AttributeDiff `json:"new"`
+}
+
+// ComparePlans reports the resource instances whose planned action, or whose
+// attributes' planned new values, differ between two plans. Sensitive values
+// are compared, but redacted in the result unless opts.ShowSensitive is set.
+func ComparePlans(old, new *terraform.Plan, opts Options) *Comparison {
+	out := &Comparison{
+		Resources: []*ResourceMismatch{},
+	}
+
+	modules := make(map[string][]string)
+	for _, d := range []*terraform.Diff{old.Diff, new.Diff} {
+		for _, m := range d.Modules {
+			modules[strings.Join(m.Path, ".")] = m.Path
+		}
+	}
+
+	for _, path := range modules {
+		oldModule, newModule := old.Diff.ModuleByPath(path), new.Diff.ModuleByPath(path)
+		names := make(map[string]bool)
+		for _, m := range []*terraform.ModuleDiff{oldModule, newModule} {
+			if m == nil {
+				continue
+			}
+			for k := range m.Resources {
+				names[k] = true
+			}
+		}
+
+		for name := range names {
+			r := compareInstanceDiffs(instance(oldModule, name), instance(newModule, name), keyMode(name), opts)
+			if r == nil {
+				continue
+			}
+			r.Path = path
+			r.Name = name
+			out.Resources = append(out.Resources, r)
+		}
+	}
+
+	sort.Sort(byMismatchAddress(out.Resources))
+	out.Equal = len(out.Resources) == 0
+	return out
+}
+
+// compareInstanceDiffs returns how two diffs of the same instance differ, or
+// nil if they plan the same changes. Either diff may be nil if the instance
+// is missing from that plan.
+func compareInstanceDiffs(old, new *terraform.InstanceDiff, mode config.ResourceMode, opts Options) *ResourceMismatch {
+	out := &ResourceMismatch{
+		Attributes: []*AttributeMismatch{},
+	}
+	if old != nil {
+		out.OldAction = instanceAction(mode, old)
+	}
+	if new != nil {
+		out.NewAction = instanceAction(mode, new)
+	}
+
+	names := make(map[string]bool)
+	for _, d := range []*terraform.InstanceDiff{old, new} {
+		if d == nil {
+			continue
+		}
+		for k := range d.Attributes {
+			names[k] = true
+		}
+	}
+	for name := range names {
+		oldAttr, newAttr := attribute(old, name), attribute(new, name)
+		if sameNewValue(oldAttr, newAttr) {
+			continue
+		}
+		out.Attributes = append(out.Attributes, &AttributeMismatch{
+			Name: name,
+			Old:  detailedAttrDiff(oldAttr, opts),
+			New:  detailedAttrDiff(newAttr, opts),
+		})
+	}
+	sort.Sort(byAttributeMismatchName(out.Attributes))
+
+	if out.OldAction == out.NewAction && len(out.Attributes) == 0 {
+		return nil
+	}
+	return out
+}
+
+// sameNewValue returns true if two attribute diffs plan the same new value.
+func sameNewValue(a, b *terraform.ResourceAttrDiff) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.New == b.New &&
+		a.NewComputed == b.NewComputed &&
+		a.NewRemoved == b.NewRemoved &&
+		a.RequiresNew == b.RequiresNew
+}
+
+// detailedAttrDiff converts diff to an AttributeDiff, or returns nil if diff
+// is nil.
+func detailedAttrDiff(diff *terraform.ResourceAttrDiff, opts Options) *AttributeDiff {
+	if diff == nil {
+		return nil
+	}
+	opts.Detailed = true
+	v := convertAttrDiff(diff, opts).(AttributeDiff)
+	return &v
+}
+
+func instance(m *terraform.ModuleDiff, name string) *terraform.InstanceDiff {
+	if m == nil {
+		return nil
+	}
+	return m.Resources[name]
+}
+
+func attribute(d *terraform.InstanceDiff, name string) *terraform.ResourceAttrDiff {
+	if d == nil {
+		return nil
+	}
+	return d.Attributes[name]
+}
+
+// StateComparison describes how two states of the same infrastructure
+// differ.
+type StateComparison struct {
+	// Equal is true if both states record the same resources and outputs,
+	// that is if Resources and Outputs are empty. Module dependencies and
+	// modules without any resources or outputs are not compared.
+	Equal bool `json:"equal"`
+
+	// SameLineage is false if the states were not created by the same
+	// "terraform apply" history, in which case Age is empty.
+	SameLineage bool `json:"same_lineage"`
+
+	// Age is "older", "newer" or "same", comparing the serial of the new
+	// state to that of the old one.
+	Age string `json:"age"`
+
+	Warnings  []string         `json:"warnings"`
+	Resources []*ResourceDrift `json:"resources"`
+	Outputs   []*OutputDrift   `json:"outputs"`
+}
+
+// ResourceDrift is a resource whose state differs between two states. Change
+// is "added", "removed" or "changed".
+type ResourceDrift struct {
+	Path       []string          `json:"path"`
+	Name       string            `json:"name"`
+	Change     string            `json:"change"`
+	Attributes []*AttributeDrift `json:"attributes"`
+}
+
+// AttributeDrift is an attribute of the primary instance of a resource whose
+// value differs between two states. Old and New are nil if the attribute is
+// missing from the old or new state respectively.
+type AttributeDrift struct {
+	Name string  `json:"name"`
+	Old  *string `json:"old"`
+	New  *string `json:"new"`
+}
+
+// OutputDrift is a module output whose value differs between two states.
+// Change is "added", "removed" or "changed", and Old and New are nil if the
+// output is missing from the old or new state respectively. The values of
+// outputs marked sensitive in either state are replaced by
+// SensitivePlaceholder.
+type OutputDrift struct {
+	Path   []string    `json:"path"`
+	Name   string      `json:"name"`
+	Change string      `json:"change"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// CompareStates reports the resources and outputs added, removed or changed
+// between two states, and warns if they are of different lineages or the new
+// state is older than the old one.
+func CompareStates(old, new *terraform.State) *StateComparison {
+	out := &StateComparison{
+		SameLineage: old.SameLineage(new),
+		Warnings:    []string{},
+		Resources:   []*ResourceDrift{},
+		Outputs:     []*OutputDrift{},
+	}
+
+	if age, err := new.CompareAges(old); err != nil {
+		out.Warnings = append(out.Warnings, fmt.Sprintf("the states have different lineages (%s and %s) and may not describe the same infrastructure", old.Lineage, new.Lineage))
+	} else {
+		switch age {
+		case terraform.StateAgeReceiverOlder:
+			out.Age = "older"
+			out.Warnings = append(out.Warnings, fmt.Sprintf("the new state (serial %d) is older than the old state (serial %d)", new.Serial, old.Serial))
+		case terraform.StateAgeReceiverNewer:
+			out.Age = "newer"
+		default:
+			out.Age = "same"
+		}
+	}
+
+	modules := make(map[string][]string)
+	for _, s := range []*terraform.State{old, new} {
+		for _, m := range s.Modules {
+			modules[strings.Join(m.Path, ".")] = m.Path
+		}
+	}
+
+	for _, path := range modules {
+		oldModule, newModule := old.ModuleByPath(path), new.ModuleByPath(path)
+		names := make(map[string]bool)
+		for _, m := range []*terraform.ModuleState{oldModule, newModule} {
+			if m == nil {
+				continue
+			}
+			for k := range m.Resources {
+				names[k] = true
+			}
+		}
+
+		for name := range names {
+			r := compareResourceStates(resourceState(oldModule, name), resourceState(newModule, name))
+			if r == nil {
+				continue
+			}
+			r.Path = path
+			r.Name = name
+			out.Resources = append(out.Resources, r)
+		}
+
+		outputs := make(map[string]bool)
+		for _, m := range []*terraform.ModuleState{oldModule, newModule} {
+			if m == nil {
+				continue
+			}
+			for k := range m.Outputs {
+				outputs[k] = true
+			}
+		}
+
+		for name := range outputs {
+			o := compareOutputStates(outputState(oldModule, name), outputState(newModule, name))
+			if o == nil {
+				continue
+			}
+			o.Path = path
+			o.Name = name
+			out.Outputs = append(out.Outputs, o)
+		}
+	}
+
+	sort.Sort(byDriftAddress(out.Resources))
+	sort.Sort(byOutputAddress(out.Outputs))
+	out.Equal = len(out.Resources) == 0 && len(out.Outputs) == 0
+	return out
+}
+
+// compareOutputStates returns how two states of the same output differ, or
+// nil if they are equal. Either state may be nil if the output is missing
+// from that state.
+func compareOutputStates(old, new *terraform.OutputState) *OutputDrift {
+	out := &OutputDrift{}
+	switch {
+	case old == nil:
+		out.Change = "added"
+	case new == nil:
+		out.Change = "removed"
+	case old.Equal(new):
+		return nil
+	default:
+		out.Change = "changed"
+	}
+
+	sensitive := (old != nil && old.Sensitive) || (new != nil && new.Sensitive)
+	if old != nil {
+		out.Old = old.Value
+	}
+	if new != nil {
+		out.New = new.Value
+	}
+	if sensitive {
+		if old != nil {
+			out.Old = SensitivePlaceholder
+		}
+		if new != nil {
+			out.New = SensitivePlaceholder
+		}
+	}
+	return out
+}
+
+// compareResourceStates returns how two states of the same resource differ,
+// or nil if they are equal. Either state may be nil if the resource is
+// missing from that state.
+func compareResourceStates(old, new *terraform.ResourceState) *ResourceDrift {
+	out := &ResourceDrift{
+		Attributes: []*AttributeDrift{},
+	}
+	switch {
+	case old == nil:
+		out.Change = "added"
+	case new == nil:
+		out.Change = "removed"
+	case old.Equal(new):
+		return nil
+	default:
+		out.Change = "changed"
+	}
+
+	oldAttrs, newAttrs := primaryAttributes(old), primaryAttributes(new)
+	names := make(map[string]bool)
+	for _, attrs := range []map[string]string{oldAttrs, newAttrs} {
+		for k := range attrs {
+			names[k] = true
+		}
+	}
+	for name := range names {
+		oldValue, inOld := oldAttrs[name]
+		newValue, inNew := newAttrs[name]
+		if inOld == inNew && oldValue == newValue {
+			continue
+		}
+		a := &AttributeDrift{Name: name}
+		if inOld {
+			a.Old = &oldValue
+		}
+		if inNew {
+			a.New = &newValue
+		}
+		out.Attributes = append(out.Attributes, a)
+	}
+	sort.Sort(byAttributeDriftName(out.Attributes))
+	return out
+}
+
+func resourceState(m *terraform.ModuleState, name string) *terraform.ResourceState {
+	if m == nil {
+		return nil
+	}
+	return m.Resources[name]
+}
+
+func outputState(m *terraform.ModuleState, name string) *terraform.OutputState {
+	if m == nil {
+		return nil
+	}
+	return m.Outputs[name]
+}
+
+func primaryAttributes(r *terraform.ResourceState) map[string]string {
+	if r == nil || r.Primary == nil {
+		return nil
+	}
+	return r.Primary.Attributes
+}
+
+// byMismatchAddress sorts resource mismatches by module path and then by name.
+type byMismatchAddress []*ResourceMismatch
+
+func (a byMismatchAddress) Len() int      { return len(a) }
+func (a byMismatchAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byMismatchAddress) Less(i, j int) bool {
+	if !pathEqual(a[i].Path, a[j].Path) {
+		return pathLess(a[i].Path, a[j].Path)
+	}
+	return a[i].Name < a[j].Name
+}
+
+// byAttributeMismatchName sorts attribute mismatches by name.
+type byAttributeMismatchName []*AttributeMismatch
+
+func (a byAttributeMismatchName) Len() int           { return len(a) }
+func (a byAttributeMismatchName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAttributeMismatchName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// byDriftAddress sorts resource drifts by module path and then by name.
+type byDriftAddress []*ResourceDrift
+
+func (a byDriftAddress) Len() int      { return len(a) }
+func (a byDriftAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byDriftAddress) Less(i, j int) bool {
+	if !pathEqual(a[i].Path, a[j].Path) {
+		return pathLess(a[i].Path, a[j].Path)
+	}
+	return a[i].Name < a[j].Name
+}
+
+// byOutputAddress sorts output drifts by module path and then by name.
+type byOutputAddress []*OutputDrift
+
+func (a byOutputAddress) Len() int      { return len(a) }
+func (a byOutputAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byOutputAddress) Less(i, j int) bool {
+	if !pathEqual(a[i].Path, a[j].Path) {
+		return pathLess(a[i].Path, a[j].Path)
+	}
+	return a[i].Name < a[j].Name
+}
+
+// byAttributeDriftName sorts attribute drifts by name.
+type byAttributeDriftName []*AttributeDrift
+
+func (a byAttributeDriftName) Len() int           { return len(a) }
+func (a byAttributeDriftName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAttributeDriftName) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,259 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestComparePlans(t *testing.T) {
+	old := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami":           {Old: "ami-1", New: "ami-2"},
+								"instance_type": {Old: "t2.micro", New: "t2.small"},
+							},
+						},
+						"aws_vpc.main":     {Destroy: true},
+						"data.aws_ami.old": {Destroy: true},
+					},
+				},
+			},
+		},
+	}
+	new := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": {
+							Destroy: true,
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami":           {Old: "ami-0", New: "ami-3", RequiresNew: true},
+								"instance_type": {Old: "t2.nano", New: "t2.small"},
+							},
+						},
+						"data.aws_ami.ubuntu": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": {NewComputed: true, RequiresNew: true},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "network"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_subnet.a": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": {NewComputed: true, RequiresNew: true},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	want := &Comparison{
+		Resources: []*ResourceMismatch{
+			{
+				Path:      []string{"root"},
+				Name:      "aws_instance.web",
+				OldAction: "update",
+				NewAction: "replace",
+				Attributes: []*AttributeMismatch{
+					{
+						Name: "ami",
+						Old:  &AttributeDiff{Old: "ami-1", New: "ami-2"},
+						New:  &AttributeDiff{Old: "ami-0", New: "ami-3", RequiresNew: true},
+					},
+				},
+			},
+			{
+				Path:       []string{"root"},
+				Name:       "aws_vpc.main",
+				OldAction:  "destroy",
+				Attributes: []*AttributeMismatch{},
+			},
+			{
+				Path:       []string{"root"},
+				Name:       "data.aws_ami.old",
+				OldAction:  "forget",
+				Attributes: []*AttributeMismatch{},
+			},
+			{
+				Path:      []string{"root"},
+				Name:      "data.aws_ami.ubuntu",
+				NewAction: "read",
+				Attributes: []*AttributeMismatch{
+					{Name: "id", New: &AttributeDiff{NewComputed: true, RequiresNew: true}},
+				},
+			},
+			{
+				Path:      []string{"root", "network"},
+				Name:      "aws_subnet.a",
+				NewAction: "create",
+				Attributes: []*AttributeMismatch{
+					{Name: "id", New: &AttributeDiff{NewComputed: true, RequiresNew: true}},
+				},
+			},
+		},
+	}
+	if got := ComparePlans(old, new, Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %+v\nActual: %+v", want, got)
+	}
+
+	if got := ComparePlans(old, old, Options{}); !got.Equal || len(got.Resources) != 0 {
+		t.Errorf("Expected plan to equal itself, got %+v", got)
+	}
+}
+
+func TestCompareStates(t *testing.T) {
+	instance := func(attrs map[string]string) *terraform.ResourceState {
+		return &terraform.ResourceState{
+			Type:    "aws_instance",
+			Primary: &terraform.InstanceState{ID: attrs["id"], Attributes: attrs},
+		}
+	}
+	old := &terraform.State{
+		Version: 3,
+		Serial:  4,
+		Lineage: "a",
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web":  instance(map[string]string{"id": "i-1", "ami": "ami-1"}),
+					"aws_instance.old":  instance(map[string]string{"id": "i-2"}),
+					"aws_instance.same": instance(map[string]string{"id": "i-3"}),
+				},
+				Outputs: map[string]*terraform.OutputState{
+					"ip":       {Type: "string", Value: "10.0.0.1"},
+					"password": {Type: "string", Value: "hunter1", Sensitive: true},
+					"same":     {Type: "string", Value: "x"},
+				},
+			},
+		},
+	}
+	new := &terraform.State{
+		Version: 3,
+		Serial:  3,
+		Lineage: "a",
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web":  instance(map[string]string{"id": "i-1", "ami": "ami-2", "tags.%": "0"}),
+					"aws_instance.same": instance(map[string]string{"id": "i-3"}),
+				},
+				Outputs: map[string]*terraform.OutputState{
+					"ip":       {Type: "string", Value: "10.0.0.2"},
+					"password": {Type: "string", Value: "hunter2", Sensitive: true},
+					"same":     {Type: "string", Value: "x"},
+					"zones":    {Type: "list", Value: []interface{}{"a", "b"}},
+				},
+			},
+			{
+				Path: []string{"root", "network"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.new": instance(map[string]string{"id": "i-4"}),
+				},
+			},
+		},
+	}
+
+	str := func(s string) *string { return &s }
+	want := &StateComparison{
+		SameLineage: true,
+		Age:         "older",
+		Warnings:    []string{"the new state (serial 3) is older than the old state (serial 4)"},
+		Resources: []*ResourceDrift{
+			{
+				Path:   []string{"root"},
+				Name:   "aws_instance.old",
+				Change: "removed",
+				Attributes: []*AttributeDrift{
+					{Name: "id", Old: str("i-2")},
+				},
+			},
+			{
+				Path:   []string{"root"},
+				Name:   "aws_instance.web",
+				Change: "changed",
+				Attributes: []*AttributeDrift{
+					{Name: "ami", Old: str("ami-1"), New: str("ami-2")},
+					{Name: "tags.%", New: str("0")},
+				},
+			},
+			{
+				Path:   []string{"root", "network"},
+				Name:   "aws_instance.new",
+				Change: "added",
+				Attributes: []*AttributeDrift{
+					{Name: "id", New: str("i-4")},
+				},
+			},
+		},
+		Outputs: []*OutputDrift{
+			{Path: []string{"root"}, Name: "ip", Change: "changed", Old: "10.0.0.1", New: "10.0.0.2"},
+			{Path: []string{"root"}, Name: "password", Change: "changed", Old: "<sensitive>", New: "<sensitive>"},
+			{Path: []string{"root"}, Name: "zones", Change: "added", New: []interface{}{"a", "b"}},
+		},
+	}
+	if got := CompareStates(old, new); !reflect.DeepEqual(got, want) {
+		gotJSON, _ := Marshal(got)
+		wantJSON, _ := Marshal(want)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+
+	new.Lineage = "b"
+	if got := CompareStates(old, new); got.SameLineage || got.Age != "" || len(got.Warnings) != 1 {
+		t.Errorf("Expected a lineage warning, got %+v", got)
+	}
+	if got := CompareStates(old, old); !got.Equal || len(got.Resources) != 0 || len(got.Outputs) != 0 {
+		t.Errorf("Expected state to equal itself, got %+v", got)
+	}
+
+	// A change to an output alone makes the states differ
+	changed := old.DeepCopy()
+	changed.RootModule().Outputs["same"].Value = "y"
+	if got := CompareStates(old, changed); got.Equal || len(got.Outputs) != 1 {
+		t.Errorf("Expected an output change, got %+v", got)
+	}
+
+	// Module dependencies are not compared
+	changed = old.DeepCopy()
+	changed.RootModule().Dependencies = []string{"aws_vpc.main"}
+	if got := CompareStates(old, changed); !got.Equal {
+		t.Errorf("Expected dependencies to be ignored, got %+v", got)
+	}
+}
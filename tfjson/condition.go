@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hil"
+	"github.com/hashicorp/hil/ast"
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Condition is a HIL expression, such as
+// `${eq(action, "replace")}`, that is evaluated against a resource instance
+// in a plan. It holds if it evaluates to "true" or "1".
+//
+// The expression can use the interpolation functions of the Terraform
+// configuration language, the predicates eq, ne, not, and, or, match (which
+// applies path.Match) and contains, and lookup with a default value. It can
+// read the following variables:
+//
+//	address         the address of the instance, such as "module.a.aws_instance.web[0]"
+//	module          the module path, such as "a.b", or "" for the root module
+//	mode            "managed" or "data"
+//	type            the resource type
+//	name            the resource name
+//	index           the count index, or "" if the resource has no count
+//	action          "create", "update", "destroy", "replace" or "no-op", or
+//	                "read" or "forget" for data sources
+//	attributes      a map of the planned new value of each changed attribute
+//	old_attributes  a map of the old value of each changed attribute
+//
+// Sensitive attribute values are SensitivePlaceholder unless
+// Options.ShowSensitive is set, so that they are not echoed in errors.
+type Condition struct {
+	expr string
+	root ast.Node
+}
+
+// ParseCondition parses a condition.
+func ParseCondition(s string) (*Condition, error) {
+	root, err := hil.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %s", s, err)
+	}
+	return &Condition{expr: s, root: root}, nil
+}
+
+// String returns the expression the condition was parsed from.
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// holds reports whether the condition holds for the instance diff d at addr.
+func (c *Condition) holds(addr *terraform.ResourceAddress, d *terraform.InstanceDiff, opts Options) (bool, error) {
+	result, err := hil.Eval(c.root, &hil.EvalConfig{
+		GlobalScope: &ast.BasicScope{
+			VarMap:  conditionVariables(addr, d, opts),
+			FuncMap: conditionFuncs(),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: condition %q: %s", addr, c.expr, err)
+	}
+
+	s, ok := result.Value.(string)
+	if !ok {
+		return false, fmt.Errorf("%s: condition %q: result is a %s, not a string", addr, c.expr, result.Type)
+	}
+	holds, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%s: condition %q: result %q is not a boolean", addr, c.expr, s)
+	}
+	return holds, nil
+}
+
+// FilterDiffCondition returns a copy of diff containing only the resources
+// for which cond holds. Modules without any such resources are omitted.
+func FilterDiffCondition(diff *terraform.Diff, cond *Condition, opts Options) (*terraform.Diff, error) {
+	return filterDiff(diff, func(addr *terraform.ResourceAddress, d *terraform.InstanceDiff) (bool, error) {
+		return cond.holds(addr, d, opts)
+	})
+}
+
+func conditionVariables(addr *terraform.ResourceAddress, d *terraform.InstanceDiff, opts Options) map[string]ast.Variable {
+	index := ""
+	if addr.Index != -1 {
+		index = strconv.Itoa(addr.Index)
+	}
+
+	attrs := make(map[string]ast.Variable)
+	oldAttrs := make(map[string]ast.Variable)
+	for k, attr := range d.Attributes {
+		if attr.Sensitive && !opts.ShowSensitive {
+			attrs[k] = stringVariable(SensitivePlaceholder)
+			oldAttrs[k] = stringVariable(SensitivePlaceholder)
+			continue
+		}
+		attrs[k] = stringVariable(attr.New)
+		oldAttrs[k] = stringVariable(attr.Old)
+	}
+
+	return map[string]ast.Variable{
+		"address":        stringVariable(addr.String()),
+		"module":         stringVariable(strings.Join(addr.Path, ".")),
+		"mode":           stringVariable(resourceMode(addr.Mode)),
+		"type":           stringVariable(addr.Type),
+		"name":           stringVariable(addr.Name),
+		"index":          stringVariable(index),
+		"action":         stringVariable(instanceAction(addr.Mode, d)),
+		"attributes":     {Type: ast.TypeMap, Value: attrs},
+		"old_attributes": {Type: ast.TypeMap, Value: oldAttrs},
+	}
+}
+
+func stringVariable(s string) ast.Variable {
+	return ast.Variable{Type: ast.TypeString, Value: s}
+}
+
+// conditionFuncs returns the functions available to conditions. HIL has no
+// boolean type, so predicates return "true" or "false".
+func conditionFuncs() map[string]ast.Function {
+	funcs := config.Funcs()
+	funcs["eq"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeString, ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			return strconv.FormatBool(args[0].(string) == args[1].(string)), nil
+		},
+	}
+	funcs["ne"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeString, ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			return strconv.FormatBool(args[0].(string) != args[1].(string)), nil
+		},
+	}
+	funcs["not"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			b, err := strconv.ParseBool(args[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			return strconv.FormatBool(!b), nil
+		},
+	}
+	funcs["and"] = boolFunc(true)
+	funcs["or"] = boolFunc(false)
+	funcs["match"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeString, ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			ok, err := path.Match(args[0].(string), args[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			return strconv.FormatBool(ok), nil
+		},
+	}
+	funcs["contains"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeList, ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			for _, v := range args[0].([]ast.Variable) {
+				if v.Type == ast.TypeString && v.Value.(string) == args[1].(string) {
+					return "true", nil
+				}
+			}
+			return "false", nil
+		},
+	}
+	funcs["lookup"] = ast.Function{
+		ArgTypes:   []ast.Type{ast.TypeMap, ast.TypeString, ast.TypeString},
+		ReturnType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			v, ok := args[0].(map[string]ast.Variable)[args[1].(string)]
+			if !ok || v.Type != ast.TypeString {
+				return args[2].(string), nil
+			}
+			return v.Value.(string), nil
+		},
+	}
+	return funcs
+}
+
+// boolFunc returns a variadic function returning the conjunction of its
+// arguments if and is true and their disjunction otherwise.
+func boolFunc(and bool) ast.Function {
+	return ast.Function{
+		ArgTypes:     []ast.Type{ast.TypeString},
+		ReturnType:   ast.TypeString,
+		Variadic:     true,
+		VariadicType: ast.TypeString,
+		Callback: func(args []interface{}) (interface{}, error) {
+			for _, arg := range args {
+				b, err := strconv.ParseBool(arg.(string))
+				if err != nil {
+					return nil, err
+				}
+				if b != and {
+					return strconv.FormatBool(!and), nil
+				}
+			}
+			return strconv.FormatBool(and), nil
+		},
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestFilterDiffCondition(t *testing.T) {
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_instance.web.0": {
+						Destroy: true,
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": {Old: "ami-1", New: "ami-2", RequiresNew: true},
+						},
+					},
+					"aws_instance.web.1": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"tags.Name": {Old: "web", New: "web-1"},
+						},
+					},
+					"data.aws_ami.ubuntu": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"id": {NewComputed: true},
+						},
+					},
+				},
+			},
+			{
+				Path: []string{"root", "network"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_subnet.a": {Destroy: true},
+				},
+			},
+		},
+	}
+
+	for i, tc := range []struct {
+		condition string
+		want      []string
+	}{
+		{`${eq(action, "replace")}`, []string{"aws_instance.web[0]"}},
+		{`${eq(mode, "data")}`, []string{"data.aws_ami.ubuntu"}},
+		{`${and(eq(type, "aws_instance"), eq(index, "1"))}`, []string{"aws_instance.web[1]"}},
+		{`${or(eq(module, "network"), ne(lookup(old_attributes, "ami", ""), ""))}`, []string{"aws_instance.web[0]", "module.network.aws_subnet.a"}},
+		{`${match("web-*", lookup(attributes, "tags.Name", ""))}`, []string{"aws_instance.web[1]"}},
+		{`${not(contains(list("destroy", "replace"), action))}`, []string{"aws_instance.web[1]", "data.aws_ami.ubuntu"}},
+		{`${address == "x"}`, nil},
+	} {
+		cond, err := ParseCondition(tc.condition)
+		if tc.want == nil {
+			if err == nil {
+				t.Errorf("case %d: Expected parse error for %s", i, tc.condition)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+
+		filtered, err := FilterDiffCondition(diff, cond, Options{})
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		got := []string{}
+		for _, m := range filtered.Modules {
+			for k := range m.Resources {
+				addr, _ := InstanceAddress(m.Path, k)
+				got = append(got, addr.String())
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("case %d: %s\nExpected: %v\nActual: %v", i, tc.condition, tc.want, got)
+		}
+	}
+
+	cond, err := ParseCondition(`${attributes["ami"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FilterDiffCondition(diff, cond, Options{}); err == nil {
+		t.Error("Expected error for condition that is not a boolean")
+	}
+}
+
+func TestFilterDiffConditionSensitive(t *testing.T) {
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_db_instance.db": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"password": {Old: "swordfish", New: "hunter2", Sensitive: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cond, err := ParseCondition(`${eq(lookup(attributes, "password", ""), "hunter2")}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts Options
+		want int
+	}{
+		{Options{}, 0},
+		{Options{ShowSensitive: true}, 1},
+	} {
+		filtered, err := FilterDiffCondition(diff, cond, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(filtered.Modules) != tc.want {
+			t.Errorf("ShowSensitive %v: expected %d modules, got %d", tc.opts.ShowSensitive, tc.want, len(filtered.Modules))
+		}
+	}
+
+	cond, err = ParseCondition(`${not(old_attributes["password"])}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = FilterDiffCondition(diff, cond, Options{})
+	if err == nil || strings.Contains(err.Error(), "swordfish") {
+		t.Errorf("Expected error without the sensitive value, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"sort"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// ModuleConfig is the configuration of a single module, identified by its
+// path from the root module. Configuration values are emitted as written,
+// with interpolations such as "${var.region}" left unevaluated.
+type ModuleConfig struct {
+	Path      []string          `json:"path"`
+	Source    string            `json:"source"`
+	Modules   []*ModuleCall     `json:"modules"`
+	Providers []*ProviderConfig `json:"providers"`
+	Resources []*ResourceConfig `json:"resources"`
+	Variables []*VariableConfig `json:"variables"`
+	Outputs   []*OutputConfig   `json:"outputs"`
+}
+
+// ModuleCall is a module block within a module.
+type ModuleCall struct {
+	Name   string                 `json:"name"`
+	Source string                 `json:"source"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// ProviderConfig is a provider block within a module.
+type ProviderConfig struct {
+	Name   string                 `json:"name"`
+	Alias  string                 `json:"alias"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// ResourceConfig is a resource or data block within a module. Provider is
+// the name of the provider the resource uses, including its alias if any.
+type ResourceConfig struct {
+	ID                  string                 `json:"id"`
+	Mode                string                 `json:"mode"`
+	Type                string                 `json:"type"`
+	Name                string                 `json:"name"`
+	Provider            string                 `json:"provider"`
+	Count               interface{}            `json:"count"`
+	DependsOn           []string               `json:"depends_on"`
+	CreateBeforeDestroy bool                   `json:"create_before_destroy"`
+	PreventDestroy      bool                   `json:"prevent_destroy"`
+	IgnoreChanges       []string               `json:"ignore_changes"`
+	Config              map[string]interface{} `json:"config"`
+}
+
+// VariableConfig is a variable block within a module.
+type VariableConfig struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+}
+
+// OutputConfig is an output block within a module.
+type OutputConfig struct {
+	Name      string      `json:"name"`
+	Sensitive bool        `json:"sensitive"`
+	Value     interface{} `json:"value"`
+}
+
+// ConvertModuleTree converts the configuration of every module in tree,
+// ordered by path.
+//
+// The defaults of variables and the arguments of providers are redacted in
+// the same way as the variables of a plan, since they may hold credentials.
+func ConvertModuleTree(tree *module.Tree, opts Options) []*ModuleConfig {
+	out := []*ModuleConfig{}
+	convertModuleTree(&out, tree, []string{"root"}, "", opts)
+	sort.Sort(byConfigPath(out))
+	return out
+}
+
+func convertModuleTree(out *[]*ModuleConfig, tree *module.Tree, path []string, source string, opts Options) {
+	c := tree.Config()
+	if c == nil {
+		c = &config.Config{}
+	}
+
+	m := &ModuleConfig{
+		Path:      path,
+		Source:    source,
+		Modules:   []*ModuleCall{},
+		Providers: []*ProviderConfig{},
+		Resources: []*ResourceConfig{},
+		Variables: []*VariableConfig{},
+		Outputs:   []*OutputConfig{},
+	}
+	sources := make(map[string]string)
+	for _, v := range c.Modules {
+		m.Modules = append(m.Modules, &ModuleCall{
+			Name:   v.Name,
+			Source: v.Source,
+			Config: redactKeys(rawConfig(v.RawConfig), opts),
+		})
+		sources[v.Name] = v.Source
+	}
+	for _, v := range c.ProviderConfigs {
+		m.Providers = append(m.Providers, &ProviderConfig{
+			Name:   v.Name,
+			Alias:  v.Alias,
+			Config: redactKeys(rawConfig(v.RawConfig), opts),
+		})
+	}
+	for _, v := range c.Resources {
+		m.Resources = append(m.Resources, convertResourceConfig(v, opts))
+	}
+	for _, v := range c.Variables {
+		m.Variables = append(m.Variables, &VariableConfig{
+			Name:        v.Name,
+			Type:        v.Type().Printable(),
+			Default:     redactVariable(v.Name, v.Default, opts),
+			Description: v.Description,
+		})
+	}
+	for _, v := range c.Outputs {
+		m.Outputs = append(m.Outputs, &OutputConfig{
+			Name:      v.Name,
+			Sensitive: v.Sensitive,
+			Value:     rawValue(v.RawConfig, "value"),
+		})
+	}
+	*out = append(*out, m)
+
+	for name, child := range tree.Children() {
+		childPath := append(append([]string{}, path...), name)
+		convertModuleTree(out, child, childPath, sources[name], opts)
+	}
+}
+
+func convertResourceConfig(r *config.Resource, opts Options) *ResourceConfig {
+	provider := r.Provider
+	if provider == "" {
+		provider = defaultProvider(r.Type)
+	}
+	out := &ResourceConfig{
+		ID:                  r.Id(),
+		Mode:                resourceMode(r.Mode),
+		Type:                r.Type,
+		Name:                r.Name,
+		Provider:            provider,
+		Count:               rawValue(r.RawCount, "count"),
+		DependsOn:           nonNil(r.DependsOn),
+		CreateBeforeDestroy: r.Lifecycle.CreateBeforeDestroy,
+		PreventDestroy:      r.Lifecycle.PreventDestroy,
+		IgnoreChanges:       nonNil(r.Lifecycle.IgnoreChanges),
+		Config:              redactKeys(rawConfig(r.RawConfig), opts),
+	}
+	return out
+}
+
+// resourceMode returns the name of a resource mode as used in resource
+// addresses.
+func resourceMode(mode config.ResourceMode) string {
+	if mode == config.DataResourceMode {
+		return "data"
+	}
+	return "managed"
+}
+
+// rawConfig returns the uninterpolated arguments of a block.
+func rawConfig(c *config.RawConfig) map[string]interface{} {
+	if c == nil || c.Raw == nil {
+		return map[string]interface{}{}
+	}
+	return c.Raw
+}
+
+// rawValue returns the uninterpolated value of key in c, or nil if it is not
+// set.
+func rawValue(c *config.RawConfig, key string) interface{} {
+	if c == nil {
+		return nil
+	}
+	return c.Raw[key]
+}
+
+// redactKeys replaces the values of keys in c that match the redaction
+// patterns for variables, including keys within nested blocks.
+func redactKeys(c map[string]interface{}, opts Options) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range c {
+		out[k] = redactVariable(k, redactNested(v, opts), opts)
+	}
+	return out
+}
+
+// redactNested applies redactKeys to the blocks within v.
+func redactNested(v interface{}, opts Options) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return redactKeys(v, opts)
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(v))
+		for i, e := range v {
+			out[i] = redactKeys(e, opts)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = redactNested(e, opts)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// byConfigPath sorts module configurations by path.
+type byConfigPath []*ModuleConfig
+
+func (a byConfigPath) Len() int           { return len(a) }
+func (a byConfigPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byConfigPath) Less(i, j int) bool { return pathLess(a[i].Path, a[j].Path) }
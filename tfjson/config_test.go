@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+)
+
+const configJSON = `{
+    "provider": {
+        "aws": {
+            "region": "us-east-1",
+            "access_key": "AKIA"
+        }
+    },
+    "variable": {
+        "db_password": {
+            "default": "hunter2"
+        }
+    },
+    "module": {
+        "db": {
+            "source": "./db",
+            "db_password": "hunter3",
+            "name": "app"
+        }
+    },
+    "resource": {
+        "aws_instance": {
+            "web": {
+                "count": 2,
+                "ami": "${var.ami}",
+                "provider": "aws.west"
+            }
+        },
+        "aws_db_instance": {
+            "db": {
+                "password": "hunter4",
+                "engine": "postgres",
+                "tags": {
+                    "api_token": "abc"
+                }
+            }
+        }
+    },
+    "data": {
+        "aws_ami": {
+            "ubuntu": {
+                "most_recent": true
+            }
+        }
+    }
+}`
+
+func TestConvertModuleTree(t *testing.T) {
+	c, err := config.LoadJSON([]byte(configJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tree is not loaded, so the module it calls is not converted
+	tree := module.NewTree("", c)
+
+	mods := ConvertModuleTree(tree, Options{})
+	if len(mods) != 1 {
+		t.Fatalf("expected 1 module, got %d", len(mods))
+	}
+	m := mods[0]
+
+	if !reflect.DeepEqual(m.Path, []string{"root"}) {
+		t.Errorf("expected root path, got %v", m.Path)
+	}
+
+	wantProvider := map[string]interface{}{"region": "us-east-1", "access_key": "<sensitive>"}
+	if len(m.Providers) != 1 || !reflect.DeepEqual(m.Providers[0].Config, wantProvider) {
+		t.Errorf("expected provider config %v, got %+v", wantProvider, m.Providers)
+	}
+
+	if len(m.Variables) != 1 || m.Variables[0].Default != "<sensitive>" {
+		t.Errorf("expected redacted variable default, got %+v", m.Variables)
+	}
+
+	wantModule := map[string]interface{}{"db_password": "<sensitive>", "name": "app"}
+	if len(m.Modules) != 1 || !reflect.DeepEqual(m.Modules[0].Config, wantModule) {
+		t.Errorf("expected module call config %v, got %+v", wantModule, m.Modules)
+	}
+
+	if len(m.Resources) != 3 {
+		t.Fatalf("expected 3 resources, got %d", len(m.Resources))
+	}
+	for _, r := range m.Resources {
+		switch r.ID {
+		case "aws_instance.web":
+			if r.Mode != "managed" || r.Provider != "aws.west" || r.Count != "2" {
+				t.Errorf("unexpected resource %+v", r)
+			}
+		case "aws_db_instance.db":
+			want := map[string]interface{}{
+				"password": "<sensitive>",
+				"engine":   "postgres",
+				"tags":     []map[string]interface{}{{"api_token": "<sensitive>"}},
+			}
+			if !reflect.DeepEqual(r.Config, want) {
+				t.Errorf("expected resource config %v, got %v", want, r.Config)
+			}
+		case "data.aws_ami.ubuntu":
+			if r.Mode != "data" || r.Provider != "aws" {
+				t.Errorf("unexpected resource %+v", r)
+			}
+		default:
+			t.Errorf("unexpected resource %s", r.ID)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// ParseFilter parses a resource address pattern. Patterns use the syntax of
+// the addresses given to terraform's -target flag, such as
+// "module.network.aws_subnet.public", except that module names, resource
+// types and resource names may contain path.Match wildcards. A pattern that
+// is only a resource type, such as "aws_iam_*", matches every resource of
+// that type.
+func ParseFilter(s string) (*terraform.ResourceAddress, error) {
+	addr, err := terraform.ParseResourceAddress(s)
+	// "data.aws_ami" parses as a managed resource of type "data", but is
+	// meant as a data source type like any address that fails to parse.
+	if err != nil || (addr.Mode == config.ManagedResourceMode && addr.Type == "data") {
+		// Try again as a resource type without a name
+		if typeAddr, typeErr := terraform.ParseResourceAddress(s + ".*"); typeErr == nil {
+			addr, err = typeAddr, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range append([]string{addr.Type, addr.Name}, addr.Path...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %q: %s", pattern, s, err)
+		}
+	}
+	return addr, nil
+}
+
+// FilterDiff returns a copy of diff containing only the resources that match
+// at least one of filters. Modules without any matching resources are
+// omitted.
+func FilterDiff(diff *terraform.Diff, filters []*terraform.ResourceAddress) *terraform.Diff {
+	out, _ := filterDiff(diff, func(addr *terraform.ResourceAddress, _ *terraform.InstanceDiff) (bool, error) {
+		return matchesAny(filters, addr), nil
+	})
+	return out
+}
+
+// filterDiff returns a copy of diff containing only the resources for which
+// keep returns true, omitting modules without any such resources. It stops
+// at the first error returned by keep.
+func filterDiff(diff *terraform.Diff, keep func(*terraform.ResourceAddress, *terraform.InstanceDiff) (bool, error)) (*terraform.Diff, error) {
+	out := &terraform.Diff{}
+	for _, m := range diff.Modules {
+		var resources map[string]*terraform.InstanceDiff
+		for k, v := range m.Resources {
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil {
+				continue
+			}
+			ok, err := keep(addr, v)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if resources == nil {
+				resources = make(map[string]*terraform.InstanceDiff)
+			}
+			resources[k] = v
+		}
+		if resources == nil {
+			continue
+		}
+		out.Modules = append(out.Modules, &terraform.ModuleDiff{
+			Path:      m.Path,
+			Resources: resources,
+			Destroy:   m.Destroy,
+		})
+	}
+	return out, nil
+}
+
+// InstanceAddress returns the address of the instance with the given key,
+// such as "aws_instance.web.0", in the module with the given path. Its String
+// method gives the canonical form, such as "module.a.aws_instance.web[0]".
+func InstanceAddress(modulePath []string, key string) (*terraform.ResourceAddress, error) {
+	k, err := terraform.ParseResourceStateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulePath) > 0 && modulePath[0] == "root" {
+		modulePath = modulePath[1:]
+	}
+	return &terraform.ResourceAddress{
+		Path:         modulePath,
+		Index:        k.Index,
+		InstanceType: terraform.TypePrimary,
+		Name:         k.Name,
+		Type:         k.Type,
+		Mode:         k.Mode,
+	}, nil
+}
+
+func matchesAny(filters []*terraform.ResourceAddress, addr *terraform.ResourceAddress) bool {
+	for _, f := range filters {
+		if matchAddress(f, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAddress reports whether addr matches filter. It follows
+// ResourceAddress.Equals, but treats the module names, type and name of
+// filter as path.Match patterns.
+func matchAddress(filter, addr *terraform.ResourceAddress) bool {
+	return len(filter.Path) == len(addr.Path) && matchAddressUnder(filter, addr)
+}
+
+// matchAddressUnder is like matchAddress, but also matches addr if it is in
+// a descendant of the module of filter. A filter without a module path
+// therefore matches in every module.
+func matchAddressUnder(filter, addr *terraform.ResourceAddress) bool {
+	if len(filter.Path) > len(addr.Path) {
+		return false
+	}
+	for i := range filter.Path {
+		if !match(filter.Path[i], addr.Path[i]) {
+			return false
+		}
+	}
+
+	indexMatch := filter.Index == -1 ||
+		addr.Index == -1 ||
+		filter.Index == addr.Index
+
+	nameMatch := filter.Name == "" ||
+		addr.Name == "" ||
+		match(filter.Name, addr.Name)
+
+	typeMatch := filter.Type == "" ||
+		addr.Type == "" ||
+		match(filter.Type, addr.Type)
+
+	// mode is significant only when type is set
+	modeMatch := filter.Type == "" ||
+		addr.Type == "" ||
+		filter.Mode == addr.Mode
+
+	return indexMatch &&
+		filter.InstanceType == addr.InstanceType &&
+		nameMatch &&
+		typeMatch &&
+		modeMatch
+}
+
+// match reports whether name matches the path.Match pattern.
+func match(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
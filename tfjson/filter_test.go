@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestFilterDiff(t *testing.T) {
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_instance.web.0":     {Destroy: true},
+					"aws_instance.web.1":     {Destroy: true},
+					"aws_iam_role.app":       {Destroy: true},
+					"aws_iam_policy.app":     {Destroy: true},
+					"data.aws_iam_policy.ro": {},
+				},
+			},
+			{
+				Path: []string{"root", "network"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_subnet.a": {Destroy: true},
+					"aws_subnet.b": {Destroy: true},
+					"aws_vpc.main": {Destroy: true},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		filter string
+		want   []string
+	}{
+		{"aws_instance.web", []string{"aws_instance.web.0", "aws_instance.web.1"}},
+		{"aws_instance.web[1]", []string{"aws_instance.web.1"}},
+		{"aws_iam_*", []string{"aws_iam_policy.app", "aws_iam_role.app"}},
+		{"data.aws_iam_*", []string{"data.aws_iam_policy.ro"}},
+		{"module.network", []string{"network/aws_subnet.a", "network/aws_subnet.b", "network/aws_vpc.main"}},
+		{"module.network.aws_subnet.*", []string{"network/aws_subnet.a", "network/aws_subnet.b"}},
+		{"module.*.aws_vpc.main", []string{"network/aws_vpc.main"}},
+		{"aws_vpc.main", nil},
+	} {
+		addr, err := ParseFilter(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, m := range FilterDiff(diff, []*terraform.ResourceAddress{addr}).Modules {
+			for k := range m.Resources {
+				if len(m.Path) > 1 {
+					k = m.Path[1] + "/" + k
+				}
+				got = append(got, k)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.filter, tc.want, got)
+		}
+	}
+
+	if _, err := ParseFilter("aws_instance.[web"); err == nil {
+		t.Errorf("expected error for malformed pattern")
+	}
+}
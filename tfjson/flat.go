@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Flat is the representation of a plan in which resource instances are
+// keyed by their canonical addresses, such as
+// "module.network.aws_subnet.a[0]", rather than nested in their modules.
+type Flat struct {
+	FormatVersion string                   `json:"format_version"`
+	Resources     map[string]*FlatResource `json:"resources"`
+}
+
+// FlatResource is the planned change to a resource instance together with
+// the parts of its address. Path is the path of its module, starting with
+// "root", and Index is nil if the resource has no count. ReadAt is "apply"
+// for data sources read when the plan is applied.
+type FlatResource struct {
+	Path           []string               `json:"path"`
+	Mode           string                 `json:"mode"`
+	ReadAt         string                 `json:"read_at,omitempty"`
+	Type           string                 `json:"type"`
+	Name           string                 `json:"name"`
+	Index          *int                   `json:"index"`
+	Action         string                 `json:"action"`
+	Destroy        bool                   `json:"destroy"`
+	DestroyTainted bool                   `json:"destroy_tainted"`
+	Attributes     map[string]interface{} `json:"attributes"`
+}
+
+// ConvertFlat converts a Terraform plan to its flat representation. Keys
+// that are not valid resource addresses are omitted.
+func ConvertFlat(plan *terraform.Plan, opts Options) *Flat {
+	out := &Flat{
+		FormatVersion: FormatVersion,
+		Resources:     make(map[string]*FlatResource),
+	}
+	for _, m := range plan.Diff.Modules {
+		for k, d := range m.Resources {
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil {
+				continue
+			}
+			r := &FlatResource{
+				Path:           append([]string{"root"}, addr.Path...),
+				Mode:           resourceMode(addr.Mode),
+				Type:           addr.Type,
+				Name:           addr.Name,
+				Action:         instanceAction(addr.Mode, d),
+				Destroy:        d.Destroy,
+				DestroyTainted: d.DestroyTainted,
+				Attributes:     convertAttributes(d.Attributes, opts),
+			}
+			if r.Action == "read" {
+				r.ReadAt = "apply"
+			}
+			if addr.Index != -1 {
+				index := addr.Index
+				r.Index = &index
+			}
+			out.Resources[addr.String()] = r
+		}
+	}
+	return out
+}
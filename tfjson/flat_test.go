@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestConvertFlat(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_vpc.main": {Destroy: true},
+						"data.aws_ami.ubuntu": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": {NewComputed: true},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "inner", "network"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_subnet.a.2": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"cidr_block": {Old: "10.0.0.0/24", New: "10.0.1.0/24"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	two := 2
+	want := &Flat{
+		FormatVersion: FormatVersion,
+		Resources: map[string]*FlatResource{
+			"aws_vpc.main": {
+				Path:       []string{"root"},
+				Mode:       "managed",
+				Type:       "aws_vpc",
+				Name:       "main",
+				Action:     "destroy",
+				Destroy:    true,
+				Attributes: map[string]interface{}{},
+			},
+			"data.aws_ami.ubuntu": {
+				Path:       []string{"root"},
+				Mode:       "data",
+				ReadAt:     "apply",
+				Type:       "aws_ami",
+				Name:       "ubuntu",
+				Action:     "read",
+				Attributes: map[string]interface{}{"id": ""},
+			},
+			"module.inner.module.network.aws_subnet.a[2]": {
+				Path:       []string{"root", "inner", "network"},
+				Mode:       "managed",
+				Type:       "aws_subnet",
+				Name:       "a",
+				Index:      &two,
+				Action:     "update",
+				Attributes: map[string]interface{}{"cidr_block": "10.0.1.0/24"},
+			},
+		},
+	}
+	if got := ConvertFlat(plan, Options{}); !reflect.DeepEqual(got, want) {
+		gotJSON, _ := Marshal(got)
+		wantJSON, _ := Marshal(want)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+}
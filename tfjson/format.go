@@ -0,0 +1,250 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Plan file formats reported by InspectPlan.
+const (
+	// FormatBinary is the gob-encoded format, starting with the magic
+	// "tfplan" and a version byte, written by Terraform 0.11 and earlier.
+	FormatBinary = "tfplan"
+
+	// FormatZip is the zip archive written by Terraform 0.12 and later.
+	FormatZip = "zip"
+
+	// FormatJSON is the JSON document printed by "terraform show -json",
+	// which is converted to the equivalent Terraform 0.7 plan.
+	FormatJSON = "json"
+
+	// FormatState is a state file rather than a plan.
+	FormatState = "state"
+
+	// FormatUnknown is anything else.
+	FormatUnknown = "unknown"
+)
+
+// binaryPlanMagic and binaryPlanVersion identify the plans that the vendored
+// terraform package can read.
+const (
+	binaryPlanMagic   = "tfplan"
+	binaryPlanVersion = 1
+)
+
+// binaryPlanGenerations are the Terraform releases that wrote each version of
+// the binary plan format.
+var binaryPlanGenerations = map[int]string{
+	1: "Terraform 0.8 and earlier",
+	2: "Terraform 0.9 to 0.11",
+}
+
+// PlanFile describes the format of a plan file and what it records about the
+// Terraform release and state it was created with.
+type PlanFile struct {
+	Format string `json:"format"`
+
+	// FormatVersion is the version byte of a binary plan, or the
+	// format_version of a JSON plan.
+	FormatVersion string `json:"format_version"`
+
+	// Generation describes the Terraform releases that write the format.
+	Generation string `json:"generation"`
+
+	// Supported is true if this build of tfjson can read the plan.
+	Supported bool `json:"supported"`
+
+	// TerraformVersion, Lineage and Serial describe the state the plan was
+	// created against, if the file records them.
+	TerraformVersion string `json:"terraform_version"`
+	Lineage          string `json:"lineage"`
+	Serial           int64  `json:"serial"`
+
+	// Entries are the names of the files in a zip plan.
+	Entries []string `json:"entries,omitempty"`
+}
+
+// InspectPlan identifies the format of the plan file with the contents b.
+func InspectPlan(b []byte) *PlanFile {
+	out := identifyPlan(b)
+	if out.Format == FormatBinary && out.Supported {
+		if plan, err := terraform.ReadPlan(bytes.NewReader(b)); err == nil && plan.State != nil {
+			out.TerraformVersion = plan.State.TFVersion
+			out.Lineage = plan.State.Lineage
+			out.Serial = plan.State.Serial
+		}
+	}
+	return out
+}
+
+// identifyPlan is InspectPlan without decoding binary plans.
+func identifyPlan(b []byte) *PlanFile {
+	switch {
+	case bytes.HasPrefix(b, []byte(binaryPlanMagic)):
+		return inspectBinaryPlan(b)
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return inspectZipPlan(b)
+	case bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")):
+		return inspectJSONPlan(b)
+	default:
+		return &PlanFile{Format: FormatUnknown}
+	}
+}
+
+// ReadRawPlan reads a Terraform plan file from r like terraform.ReadPlan, but
+// returns an error explaining which Terraform releases wrote the file if it
+// is in a format this build cannot read.
+func ReadRawPlan(r io.Reader) (*terraform.Plan, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info := identifyPlan(b)
+	if !info.Supported {
+		return nil, info.unsupportedError()
+	}
+	if info.Format == FormatJSON {
+		return readShowPlan(b)
+	}
+	return terraform.ReadPlan(bytes.NewReader(b))
+}
+
+func inspectBinaryPlan(b []byte) *PlanFile {
+	out := &PlanFile{
+		Format:     FormatBinary,
+		Generation: "Terraform 0.11 and earlier",
+	}
+	if len(b) <= len(binaryPlanMagic) {
+		return out
+	}
+
+	version := int(b[len(binaryPlanMagic)])
+	out.FormatVersion = strconv.Itoa(version)
+	if g, ok := binaryPlanGenerations[version]; ok {
+		out.Generation = g
+	}
+	out.Supported = version == binaryPlanVersion
+	return out
+}
+
+func inspectZipPlan(b []byte) *PlanFile {
+	out := &PlanFile{
+		Format:     FormatZip,
+		Generation: "Terraform 0.12 and later",
+		Entries:    []string{},
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return out
+	}
+	for _, f := range r.File {
+		out.Entries = append(out.Entries, f.Name)
+		if f.Name != "tfstate" {
+			continue
+		}
+		// The state the plan was created against
+		if rc, err := f.Open(); err == nil {
+			var state stateHeader
+			if json.NewDecoder(rc).Decode(&state) == nil {
+				out.TerraformVersion = state.TerraformVersion
+				out.Lineage = state.Lineage
+				out.Serial = state.Serial
+			}
+			rc.Close()
+		}
+	}
+	sort.Strings(out.Entries)
+	return out
+}
+
+func inspectJSONPlan(b []byte) *PlanFile {
+	var doc struct {
+		stateHeader
+		FormatVersion   string          `json:"format_version"`
+		ResourceChanges json.RawMessage `json:"resource_changes"`
+		PlannedValues   json.RawMessage `json:"planned_values"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return &PlanFile{Format: FormatUnknown}
+	}
+
+	switch {
+	case doc.ResourceChanges != nil || doc.PlannedValues != nil:
+		return &PlanFile{
+			Format:           FormatJSON,
+			FormatVersion:    doc.FormatVersion,
+			Generation:       "terraform show -json, Terraform 0.12 and later",
+			Supported:        strings.HasPrefix(doc.FormatVersion, "0.") || strings.HasPrefix(doc.FormatVersion, "1."),
+			TerraformVersion: doc.TerraformVersion,
+		}
+	case doc.Lineage != "" || doc.Serial != 0:
+		return &PlanFile{
+			Format:           FormatState,
+			TerraformVersion: doc.TerraformVersion,
+			Lineage:          doc.Lineage,
+			Serial:           doc.Serial,
+		}
+	default:
+		return &PlanFile{Format: FormatUnknown}
+	}
+}
+
+// stateHeader is the part of a JSON state file that is common to all state
+// versions.
+type stateHeader struct {
+	TerraformVersion string `json:"terraform_version"`
+	Lineage          string `json:"lineage"`
+	Serial           int64  `json:"serial"`
+}
+
+// unsupportedError returns an error describing why the plan cannot be read
+// and what to do instead.
+func (f *PlanFile) unsupportedError() error {
+	switch f.Format {
+	case FormatBinary:
+		return fmt.Errorf("plan file format version %s was written by %s, but this build of tfjson only reads version %d plans written by %s; run tfjson built against the Terraform release that created the plan",
+			f.FormatVersion, f.Generation, binaryPlanVersion, binaryPlanGenerations[binaryPlanVersion])
+	case FormatZip:
+		return fmt.Errorf("plan file was written by %s, which this build of tfjson cannot read; convert it with \"terraform show -json\" and pass the result to tfjson instead",
+			f.Generation)
+	case FormatJSON:
+		return fmt.Errorf("JSON plan format version %s is not supported; only versions 0.x and 1.x are", f.FormatVersion)
+	case FormatState:
+		return fmt.Errorf("file is a Terraform state, not a plan; use tfjson state to convert it")
+	default:
+		return fmt.Errorf("not a Terraform plan file")
+	}
+}
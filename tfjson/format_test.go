@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestInspectPlan(t *testing.T) {
+	var plan bytes.Buffer
+	if err := terraform.WritePlan(instancePlan("aws_instance.web", &terraform.InstanceDiff{}), &plan); err != nil {
+		t.Fatal(err)
+	}
+	v2 := append([]byte("tfplan\x02"), plan.Bytes()[7:]...)
+
+	var zipPlan bytes.Buffer
+	w := zip.NewWriter(&zipPlan)
+	for name, contents := range map[string]string{
+		"tfplan":  "",
+		"tfstate": `{"version": 4, "terraform_version": "0.12.31", "serial": 7, "lineage": "abc"}`,
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(contents))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		contents  []byte
+		want      PlanFile
+		errPrefix string
+	}{
+		{
+			contents: plan.Bytes(),
+			want:     PlanFile{Format: FormatBinary, FormatVersion: "1", Generation: "Terraform 0.8 and earlier", Supported: true},
+		},
+		{
+			contents:  v2,
+			want:      PlanFile{Format: FormatBinary, FormatVersion: "2", Generation: "Terraform 0.9 to 0.11"},
+			errPrefix: "plan file format version 2 was written by Terraform 0.9 to 0.11",
+		},
+		{
+			contents: zipPlan.Bytes(),
+			want: PlanFile{
+				Format:           FormatZip,
+				Generation:       "Terraform 0.12 and later",
+				TerraformVersion: "0.12.31",
+				Lineage:          "abc",
+				Serial:           7,
+				Entries:          []string{"tfplan", "tfstate"},
+			},
+			errPrefix: "plan file was written by Terraform 0.12 and later",
+		},
+		{
+			contents:  []byte(`{"version": 3, "serial": 4, "lineage": "abc"}`),
+			want:      PlanFile{Format: FormatState, Lineage: "abc", Serial: 4},
+			errPrefix: "file is a Terraform state",
+		},
+		{
+			contents:  []byte("hello"),
+			want:      PlanFile{Format: FormatUnknown},
+			errPrefix: "not a Terraform plan file",
+		},
+	} {
+		got := InspectPlan(tc.contents)
+		if !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("case %d:\nExpected: %+v\nActual: %+v", i, tc.want, *got)
+		}
+
+		_, err := ReadRawPlan(bytes.NewReader(tc.contents))
+		switch {
+		case tc.errPrefix == "" && err != nil:
+			t.Errorf("case %d: %v", i, err)
+		case tc.errPrefix != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.errPrefix)):
+			t.Errorf("case %d: Expected error starting with %q, got %v", i, tc.errPrefix, err)
+		}
+	}
+}
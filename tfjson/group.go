@@ -0,0 +1,260 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Grouped is the representation of a plan in which the instances of a
+// resource with a count, such as "aws_instance.web.0" and
+// "aws_instance.web.1", are grouped under the resource.
+type Grouped struct {
+	FormatVersion string           `json:"format_version"`
+	Modules       []*GroupedModule `json:"modules"`
+}
+
+// GroupedModule is the diff of a single module with its instances grouped by
+// resource.
+type GroupedModule struct {
+	Path      []string         `json:"path"`
+	Action    string           `json:"action"`
+	Destroy   bool             `json:"destroy"`
+	Resources []*ResourceGroup `json:"resources"`
+}
+
+// ResourceGroup is the diff of the instances of a single resource. Name is
+// the key of the resource within its module without an index, such as
+// "aws_instance.web". Action is the action taken on every instance, or
+// "update" if the instances have different actions, and Counts counts the
+// instances with each action.
+//
+// Count is set if instances are only added to or removed from the end of the
+// resource, as happens when its count changes.
+type ResourceGroup struct {
+	Name      string             `json:"name"`
+	Mode      string             `json:"mode"`
+	Action    string             `json:"action"`
+	Counts    Counts             `json:"counts"`
+	Count     *CountChange       `json:"count,omitempty"`
+	Instances []*GroupedInstance `json:"instances"`
+}
+
+// CountChange is the number of instances of a resource before and after a
+// plan is applied.
+type CountChange struct {
+	Old int `json:"old"`
+	New int `json:"new"`
+}
+
+// GroupedInstance is the diff of a single instance of a resource. Index is
+// nil if the resource has no count.
+type GroupedInstance struct {
+	Index          *int                   `json:"index"`
+	Action         string                 `json:"action"`
+	ReadAt         string                 `json:"read_at,omitempty"`
+	Destroy        bool                   `json:"destroy"`
+	DestroyTainted bool                   `json:"destroy_tainted"`
+	Attributes     map[string]interface{} `json:"attributes"`
+}
+
+// ConvertGrouped converts a Terraform plan to its grouped representation.
+// Keys that are not valid resource keys are omitted. The count of a resource
+// is only reported if the plan includes its state; a module missing from the
+// state is new and has no instances yet.
+func ConvertGrouped(plan *terraform.Plan, opts Options) *Grouped {
+	out := &Grouped{
+		FormatVersion: FormatVersion,
+		Modules:       []*GroupedModule{},
+	}
+	for _, m := range plan.Diff.Modules {
+		var state *terraform.ModuleState
+		if plan.State != nil {
+			state = plan.State.ModuleByPath(m.Path)
+			if state == nil {
+				state = &terraform.ModuleState{Path: m.Path}
+			}
+		}
+		out.Modules = append(out.Modules, convertGroupedModule(m, state, opts))
+	}
+	sort.Sort(byGroupedPath(out.Modules))
+	return out
+}
+
+func convertGroupedModule(diff *terraform.ModuleDiff, state *terraform.ModuleState, opts Options) *GroupedModule {
+	out := &GroupedModule{
+		Path:      diff.Path,
+		Action:    moduleAction(diff),
+		Destroy:   diff.Destroy,
+		Resources: []*ResourceGroup{},
+	}
+	groups := make(map[string]*ResourceGroup)
+	for k, d := range diff.Resources {
+		name, index, err := splitKey(k)
+		if err != nil {
+			continue
+		}
+		g := groups[name]
+		if g == nil {
+			g = &ResourceGroup{
+				Name:      name,
+				Mode:      resourceMode(keyMode(k)),
+				Instances: []*GroupedInstance{},
+			}
+			groups[name] = g
+			out.Resources = append(out.Resources, g)
+		}
+
+		i := &GroupedInstance{
+			Action:         instanceAction(keyMode(k), d),
+			Destroy:        d.Destroy,
+			DestroyTainted: d.DestroyTainted,
+			Attributes:     convertAttributes(d.Attributes, opts),
+		}
+		if i.Action == "read" {
+			i.ReadAt = "apply"
+		}
+		if index != -1 {
+			i.Index = &index
+		}
+		g.Counts.add(i.Action)
+		g.Instances = append(g.Instances, i)
+	}
+
+	for _, g := range out.Resources {
+		sort.Sort(byInstanceIndex(g.Instances))
+		g.Action = g.Instances[0].Action
+		for _, i := range g.Instances[1:] {
+			if i.Action != g.Action {
+				g.Action = "update"
+			}
+		}
+		if state != nil {
+			g.Count = countChange(g, state)
+		}
+	}
+	sort.Sort(byGroupName(out.Resources))
+	return out
+}
+
+// countChange returns the change to the count of the resource of g, given the
+// state of its module, or nil if its instances are not only added to or
+// removed from the end.
+func countChange(g *ResourceGroup, state *terraform.ModuleState) *CountChange {
+	old := make(map[int]bool)
+	for k := range state.Resources {
+		name, index, err := splitKey(k)
+		if err != nil || name != g.Name {
+			continue
+		}
+		if index == -1 {
+			index = 0
+		}
+		old[index] = true
+	}
+
+	created := make(map[int]bool)
+	destroyed := make(map[int]bool)
+	for _, i := range g.Instances {
+		switch i.Action {
+		case "create":
+			created[instanceIndex(i)] = true
+		case "destroy":
+			destroyed[instanceIndex(i)] = true
+		}
+	}
+	if len(created) == 0 && len(destroyed) == 0 {
+		return nil
+	}
+
+	out := &CountChange{Old: len(old), New: len(old) - len(destroyed) + len(created)}
+	for index := range old {
+		if index >= out.Old || created[index] || (destroyed[index] && index < out.New) {
+			return nil
+		}
+	}
+	for index := range created {
+		if index < out.Old || index >= out.New {
+			return nil
+		}
+	}
+	for index := range destroyed {
+		if !old[index] {
+			return nil
+		}
+	}
+	return out
+}
+
+// splitKey splits the key of an instance in a module diff or state into the
+// key of its resource and its index, which is -1 if the resource has no
+// count.
+func splitKey(key string) (string, int, error) {
+	k, err := terraform.ParseResourceStateKey(key)
+	if err != nil {
+		return "", 0, err
+	}
+	name, index := key, ""
+	if k.Index != -1 {
+		i := strings.LastIndex(key, ".")
+		name, index = key[:i], key[i+1:]
+	}
+	n, err := terraform.ParseResourceIndex(index)
+	if err != nil {
+		return "", 0, err
+	}
+	return name, n, nil
+}
+
+// instanceIndex returns the index of i, treating an instance without an index
+// as the first.
+func instanceIndex(i *GroupedInstance) int {
+	if i.Index == nil {
+		return 0
+	}
+	return *i.Index
+}
+
+// byGroupedPath sorts grouped modules by path.
+type byGroupedPath []*GroupedModule
+
+func (a byGroupedPath) Len() int           { return len(a) }
+func (a byGroupedPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byGroupedPath) Less(i, j int) bool { return pathLess(a[i].Path, a[j].Path) }
+
+// byGroupName sorts resource groups by name.
+type byGroupName []*ResourceGroup
+
+func (a byGroupName) Len() int           { return len(a) }
+func (a byGroupName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byGroupName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// byInstanceIndex sorts grouped instances by count index.
+type byInstanceIndex []*GroupedInstance
+
+func (a byInstanceIndex) Len() int           { return len(a) }
+func (a byInstanceIndex) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byInstanceIndex) Less(i, j int) bool { return instanceIndex(a[i]) < instanceIndex(a[j]) }
@@ -0,0 +1,178 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestConvertGrouped(t *testing.T) {
+	create := &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"id": {NewComputed: true, RequiresNew: true},
+		},
+	}
+	update := &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": {Old: "ami-1", New: "ami-2"},
+		},
+	}
+	destroy := &terraform.InstanceDiff{Destroy: true}
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						// Grown from 2 to 4 instances
+						"aws_instance.web.2": create,
+						"aws_instance.web.3": create,
+						// Shrunk from 3 to 1 instance
+						"aws_instance.db.1": destroy,
+						"aws_instance.db.2": destroy,
+						// A middle instance is destroyed
+						"aws_instance.app.0": update,
+						"aws_instance.app.1": destroy,
+						// No count
+						"aws_vpc.main": update,
+						"malformed":    update,
+					},
+				},
+				{
+					Path: []string{"root", "new"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web.0": create,
+						"aws_instance.web.1": create,
+						"aws_instance.web.2": create,
+					},
+				},
+			},
+		},
+		State: &terraform.State{
+			Modules: []*terraform.ModuleState{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.ResourceState{
+						"aws_instance.web.0": {},
+						"aws_instance.web.1": {},
+						"aws_instance.db.0":  {},
+						"aws_instance.db.1":  {},
+						"aws_instance.db.2":  {},
+						"aws_instance.app.0": {},
+						"aws_instance.app.1": {},
+						"aws_instance.app.2": {},
+						"aws_vpc.main":       {},
+					},
+				},
+			},
+		},
+	}
+
+	index := func(i int) *int { return &i }
+	instance := func(i *int, action string) *GroupedInstance {
+		out := &GroupedInstance{Index: i, Action: action, Attributes: map[string]interface{}{}}
+		switch action {
+		case "create":
+			out.Attributes["id"] = ""
+		case "update":
+			out.Attributes["ami"] = "ami-2"
+		case "destroy":
+			out.Destroy = true
+		}
+		return out
+	}
+	want := &Grouped{
+		FormatVersion: FormatVersion,
+		Modules: []*GroupedModule{
+			{
+				Path:   []string{"root"},
+				Action: "update",
+				Resources: []*ResourceGroup{
+					{
+						Name:      "aws_instance.app",
+						Mode:      "managed",
+						Action:    "update",
+						Counts:    Counts{Change: 1, Destroy: 1},
+						Instances: []*GroupedInstance{instance(index(0), "update"), instance(index(1), "destroy")},
+					},
+					{
+						Name:      "aws_instance.db",
+						Mode:      "managed",
+						Action:    "destroy",
+						Counts:    Counts{Destroy: 2},
+						Count:     &CountChange{Old: 3, New: 1},
+						Instances: []*GroupedInstance{instance(index(1), "destroy"), instance(index(2), "destroy")},
+					},
+					{
+						Name:      "aws_instance.web",
+						Mode:      "managed",
+						Action:    "create",
+						Counts:    Counts{Add: 2},
+						Count:     &CountChange{Old: 2, New: 4},
+						Instances: []*GroupedInstance{instance(index(2), "create"), instance(index(3), "create")},
+					},
+					{
+						Name:      "aws_vpc.main",
+						Mode:      "managed",
+						Action:    "update",
+						Counts:    Counts{Change: 1},
+						Instances: []*GroupedInstance{instance(nil, "update")},
+					},
+				},
+			},
+			{
+				// The module is missing from the state since it is new
+				Path:   []string{"root", "new"},
+				Action: "create",
+				Resources: []*ResourceGroup{
+					{
+						Name:      "aws_instance.web",
+						Mode:      "managed",
+						Action:    "create",
+						Counts:    Counts{Add: 3},
+						Count:     &CountChange{Old: 0, New: 3},
+						Instances: []*GroupedInstance{instance(index(0), "create"), instance(index(1), "create"), instance(index(2), "create")},
+					},
+				},
+			},
+		},
+	}
+	if got := ConvertGrouped(plan, Options{}); !reflect.DeepEqual(got, want) {
+		gotJSON, _ := Marshal(got)
+		wantJSON, _ := Marshal(want)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+
+	// Without the state the previous counts are unknown
+	plan.State = nil
+	for _, m := range ConvertGrouped(plan, Options{}).Modules {
+		for _, g := range m.Resources {
+			if g.Count != nil {
+				t.Errorf("%s: expected no count, got %+v", g.Name, g.Count)
+			}
+		}
+	}
+}
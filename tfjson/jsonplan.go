@@ -0,0 +1,482 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// JSONPlanFormatVersion is the version of the JSON plan representation that
+// ConvertJSONPlan emits.
+const JSONPlanFormatVersion = "1.0"
+
+// JSONPlan is the JSON plan representation printed by "terraform show -json"
+// in Terraform 0.12 and later, limited to the parts that have an equivalent
+// in a Terraform 0.7 plan.
+type JSONPlan struct {
+	FormatVersion    string                   `json:"format_version"`
+	TerraformVersion string                   `json:"terraform_version,omitempty"`
+	Variables        map[string]*JSONVariable `json:"variables,omitempty"`
+	ResourceChanges  []*JSONResourceChange    `json:"resource_changes"`
+	PriorState       *JSONState               `json:"prior_state,omitempty"`
+}
+
+// JSONVariable is the value of a root module variable.
+type JSONVariable struct {
+	Value interface{} `json:"value"`
+}
+
+// JSONResourceChange is the planned change to a resource instance.
+type JSONResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address,omitempty"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index,omitempty"`
+	ProviderName  string      `json:"provider_name"`
+	Deposed       string      `json:"deposed,omitempty"`
+	Change        *JSONChange `json:"change"`
+	ActionReason  string      `json:"action_reason,omitempty"`
+}
+
+// JSONChange describes a change by the values of an object before and after
+// it. AfterUnknown, BeforeSensitive and AfterSensitive mirror the structure
+// of the values, with true for the values that are unknown or sensitive.
+type JSONChange struct {
+	Actions         []string        `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown"`
+	BeforeSensitive interface{}     `json:"before_sensitive"`
+	AfterSensitive  interface{}     `json:"after_sensitive"`
+	ReplacePaths    [][]interface{} `json:"replace_paths,omitempty"`
+}
+
+// JSONState is the JSON state representation.
+type JSONState struct {
+	FormatVersion    string           `json:"format_version,omitempty"`
+	TerraformVersion string           `json:"terraform_version,omitempty"`
+	Values           *JSONStateValues `json:"values,omitempty"`
+}
+
+// JSONStateValues are the outputs of the root module and the resources of
+// every module in a state.
+type JSONStateValues struct {
+	Outputs    map[string]*JSONOutput `json:"outputs,omitempty"`
+	RootModule *JSONModule            `json:"root_module"`
+}
+
+// JSONOutput is the value of a root module output.
+type JSONOutput struct {
+	Sensitive bool        `json:"sensitive"`
+	Value     interface{} `json:"value"`
+}
+
+// JSONModule is a module in a state, identified by an address such as
+// "module.a.module.b".
+type JSONModule struct {
+	Address      string          `json:"address,omitempty"`
+	Resources    []*JSONResource `json:"resources,omitempty"`
+	ChildModules []*JSONModule   `json:"child_modules,omitempty"`
+}
+
+// JSONResource is a resource instance in a state.
+type JSONResource struct {
+	Address         string      `json:"address"`
+	Mode            string      `json:"mode"`
+	Type            string      `json:"type"`
+	Name            string      `json:"name"`
+	Index           interface{} `json:"index,omitempty"`
+	ProviderName    string      `json:"provider_name"`
+	Values          interface{} `json:"values"`
+	SensitiveValues interface{} `json:"sensitive_values,omitempty"`
+	DependsOn       []string    `json:"depends_on,omitempty"`
+}
+
+// ConvertJSONPlan converts a Terraform plan to the JSON plan representation
+// of later Terraform releases, so that tools written for those releases can
+// read it. Attribute values are strings, as Terraform 0.7 records them.
+// Values from the prior state are used as the values before each change
+// where the plan includes the state, and the old values in the diff
+// otherwise.
+func ConvertJSONPlan(plan *terraform.Plan, opts Options) *JSONPlan {
+	out := &JSONPlan{
+		FormatVersion:   JSONPlanFormatVersion,
+		Variables:       make(map[string]*JSONVariable),
+		ResourceChanges: []*JSONResourceChange{},
+	}
+	for k, v := range convertVariables(plan.Vars, opts) {
+		out.Variables[k] = &JSONVariable{Value: v}
+	}
+
+	for _, m := range plan.Diff.Modules {
+		for k, d := range m.Resources {
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil {
+				continue
+			}
+			out.ResourceChanges = append(out.ResourceChanges, jsonResourceChange(plan, m.Path, k, addr, d, opts))
+		}
+	}
+	sort.Sort(byChangeAddress(out.ResourceChanges))
+
+	if plan.State != nil {
+		out.TerraformVersion = plan.State.TFVersion
+		// State attributes are not marked sensitive, so those that are
+		// sensitive in the diff are redacted from the prior state as well
+		out.PriorState = jsonState(plan.State, sensitiveAttributes(plan), opts)
+	}
+	return out
+}
+
+func jsonResourceChange(plan *terraform.Plan, path []string, key string, addr *terraform.ResourceAddress, d *terraform.InstanceDiff, opts Options) *JSONResourceChange {
+	out := &JSONResourceChange{
+		Address:       addr.String(),
+		ModuleAddress: moduleAddress(addr.Path),
+		Mode:          resourceMode(addr.Mode),
+		Type:          addr.Type,
+		Name:          addr.Name,
+		ProviderName:  defaultProvider(addr.Type),
+	}
+	if addr.Index != -1 {
+		out.Index = addr.Index
+	}
+	if d.DestroyTainted {
+		out.ActionReason = "replace_because_tainted"
+	}
+
+	// The values before the change come from the prior state if possible
+	var prior *terraform.InstanceState
+	if plan.State != nil {
+		if m := plan.State.ModuleByPath(path); m != nil {
+			if r := m.Resources[key]; r != nil {
+				prior = r.Primary
+				if r.Provider != "" {
+					out.ProviderName = r.Provider
+				}
+			}
+		}
+	}
+	before := make(map[string]string)
+	if prior != nil {
+		for k, v := range prior.Attributes {
+			before[k] = v
+		}
+	} else {
+		for k, attr := range d.Attributes {
+			if attr.Old != "" {
+				before[k] = attr.Old
+			}
+		}
+	}
+
+	after := make(map[string]string)
+	for k, v := range before {
+		after[k] = v
+	}
+	unknown := make(map[string]bool)
+	sensitive := make(map[string]bool)
+	a := action(d.ChangeType())
+	var replacePaths [][]interface{}
+	for k, attr := range d.Attributes {
+		if attr.Sensitive {
+			sensitive[k] = true
+		}
+		switch {
+		case computed(attr):
+			delete(after, k)
+			unknown[k] = true
+		case attr.NewRemoved:
+			delete(after, k)
+		default:
+			after[k] = attr.New
+		}
+		if attr.RequiresNew && a == "replace" {
+			replacePaths = append(replacePaths, pathSteps(k))
+		}
+	}
+	// The elements of a list or map whose count is unknown are unknown too
+	for k := range unknown {
+		if strings.HasSuffix(k, ".#") || strings.HasSuffix(k, ".%") {
+			prefix := k[:len(k)-1]
+			for ak := range after {
+				if strings.HasPrefix(ak, prefix) {
+					delete(after, ak)
+				}
+			}
+		}
+	}
+	sort.Sort(byJoinedPath(replacePaths))
+
+	out.Change = &JSONChange{
+		Actions:         jsonActions(a, addr, plan.Module),
+		Before:          jsonValues(before, sensitive, opts),
+		After:           jsonValues(after, sensitive, opts),
+		AfterUnknown:    markValues(after, unknown),
+		BeforeSensitive: markValues(before, sensitive),
+		AfterSensitive:  markValues(after, sensitive),
+		ReplacePaths:    replacePaths,
+	}
+	switch a {
+	case "create":
+		out.Change.Before = nil
+		out.Change.BeforeSensitive = false
+	case "destroy":
+		out.Change.After = nil
+		out.Change.AfterSensitive = false
+		out.Change.AfterUnknown = map[string]interface{}{}
+	}
+	return out
+}
+
+// jsonActions returns the actions of a change with the given action to the
+// instance at addr, taking the lifecycle of its resource from tree if
+// available.
+func jsonActions(action string, addr *terraform.ResourceAddress, tree *module.Tree) []string {
+	// Terraform 0.7 plans the read of a data source as a change to it
+	if addr.Mode == config.DataResourceMode && (action == "create" || action == "update") {
+		return []string{"read"}
+	}
+
+	switch action {
+	case "create":
+		return []string{"create"}
+	case "update":
+		return []string{"update"}
+	case "destroy":
+		return []string{"delete"}
+	case "replace":
+		if createBeforeDestroy(addr, tree) {
+			return []string{"create", "delete"}
+		}
+		return []string{"delete", "create"}
+	default:
+		return []string{"no-op"}
+	}
+}
+
+// createBeforeDestroy reports whether the resource of the instance at addr is
+// configured to be replaced by creating its replacement first.
+func createBeforeDestroy(addr *terraform.ResourceAddress, tree *module.Tree) bool {
+	if tree == nil {
+		return false
+	}
+	if tree = tree.Child(addr.Path); tree == nil || tree.Config() == nil {
+		return false
+	}
+	for _, r := range tree.Config().Resources {
+		if r.Mode == addr.Mode && r.Type == addr.Type && r.Name == addr.Name {
+			return r.Lifecycle.CreateBeforeDestroy
+		}
+	}
+	return false
+}
+
+// jsonState converts a state, redacting the attributes of each instance that
+// are marked in sensitive under its address.
+func jsonState(state *terraform.State, sensitive map[string]map[string]bool, opts Options) *JSONState {
+	out := &JSONState{
+		FormatVersion:    JSONPlanFormatVersion,
+		TerraformVersion: state.TFVersion,
+		Values: &JSONStateValues{
+			Outputs:    make(map[string]*JSONOutput),
+			RootModule: &JSONModule{},
+		},
+	}
+
+	modules := map[string]*JSONModule{"": out.Values.RootModule}
+	var moduleFor func(path []string) *JSONModule
+	moduleFor = func(path []string) *JSONModule {
+		addr := moduleAddress(path)
+		if m, ok := modules[addr]; ok {
+			return m
+		}
+		m := &JSONModule{Address: addr}
+		modules[addr] = m
+		parent := moduleFor(path[:len(path)-1])
+		parent.ChildModules = append(parent.ChildModules, m)
+		return m
+	}
+
+	for _, ms := range state.Modules {
+		path := ms.Path
+		if len(path) > 0 && path[0] == "root" {
+			path = path[1:]
+		}
+		m := moduleFor(path)
+		if len(path) == 0 {
+			for k, v := range ms.Outputs {
+				out.Values.Outputs[k] = &JSONOutput{
+					Sensitive: v.Sensitive,
+					Value:     convertOutputState(v, opts).Value,
+				}
+			}
+		}
+
+		for k, r := range ms.Resources {
+			addr, err := InstanceAddress(ms.Path, k)
+			if err != nil || r.Primary == nil {
+				continue
+			}
+			resource := &JSONResource{
+				Address:      addr.String(),
+				Mode:         resourceMode(addr.Mode),
+				Type:         addr.Type,
+				Name:         addr.Name,
+				ProviderName: r.Provider,
+				Values:       jsonValues(r.Primary.Attributes, sensitive[addr.String()], opts),
+				DependsOn:    r.Dependencies,
+			}
+			if addr.Index != -1 {
+				resource.Index = addr.Index
+			}
+			if marked := sensitive[addr.String()]; len(marked) > 0 {
+				resource.SensitiveValues = markValues(r.Primary.Attributes, marked)
+			}
+			if resource.ProviderName == "" {
+				resource.ProviderName = defaultProvider(addr.Type)
+			}
+			m.Resources = append(m.Resources, resource)
+		}
+		sort.Sort(byResourceAddress(m.Resources))
+	}
+
+	for _, m := range modules {
+		sort.Sort(byModuleAddress(m.ChildModules))
+	}
+	return out
+}
+
+// jsonValues expands flattened attributes into an object, redacting the
+// sensitive ones unless opts.ShowSensitive is set.
+func jsonValues(attrs map[string]string, sensitive map[string]bool, opts Options) interface{} {
+	values := make(map[string]interface{})
+	for k, v := range attrs {
+		values[k] = v
+		if sensitive[k] && !opts.ShowSensitive {
+			values[k] = SensitivePlaceholder
+		}
+	}
+	return expand(values, func(string) bool { return false })
+}
+
+// markValues returns an object with the structure of the expanded attrs and
+// true for each of the marked keys, omitting unmarked values.
+func markValues(attrs map[string]string, marked map[string]bool) interface{} {
+	values := make(map[string]interface{})
+	for k, v := range attrs {
+		if strings.HasSuffix(k, ".#") || strings.HasSuffix(k, ".%") {
+			values[k] = v
+		} else {
+			values[k] = false
+		}
+	}
+	for k := range marked {
+		values[k] = true
+	}
+	return pruneFalse(expand(values, func(k string) bool { return marked[k] }))
+}
+
+// pruneFalse removes the false values from the maps within v.
+func pruneFalse(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e == false {
+				delete(v, k)
+			} else {
+				v[k] = pruneFalse(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = pruneFalse(e)
+		}
+	}
+	return v
+}
+
+// moduleAddress returns the address of the module at path, such as
+// "module.a.module.b", or "" for the root module.
+func moduleAddress(path []string) string {
+	var parts []string
+	for _, p := range path {
+		parts = append(parts, "module."+p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// pathSteps splits a flattened attribute key into the steps of an attribute
+// path, with list indexes as numbers.
+func pathSteps(key string) []interface{} {
+	var steps []interface{}
+	for _, s := range strings.Split(key, ".") {
+		if i, err := strconv.Atoi(s); err == nil {
+			steps = append(steps, i)
+		} else {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// defaultProvider returns the provider of a resource type that is not
+// configured with a provider alias, such as "aws" for "aws_instance".
+func defaultProvider(resourceType string) string {
+	return strings.SplitN(resourceType, "_", 2)[0]
+}
+
+// byChangeAddress sorts resource changes by address.
+type byChangeAddress []*JSONResourceChange
+
+func (a byChangeAddress) Len() int           { return len(a) }
+func (a byChangeAddress) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byChangeAddress) Less(i, j int) bool { return a[i].Address < a[j].Address }
+
+// byResourceAddress sorts state resources by address.
+type byResourceAddress []*JSONResource
+
+func (a byResourceAddress) Len() int           { return len(a) }
+func (a byResourceAddress) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byResourceAddress) Less(i, j int) bool { return a[i].Address < a[j].Address }
+
+// byModuleAddress sorts state modules by address.
+type byModuleAddress []*JSONModule
+
+func (a byModuleAddress) Len() int           { return len(a) }
+func (a byModuleAddress) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byModuleAddress) Less(i, j int) bool { return a[i].Address < a[j].Address }
+
+// byJoinedPath sorts attribute paths by their flattened keys.
+type byJoinedPath [][]interface{}
+
+func (a byJoinedPath) Len() int           { return len(a) }
+func (a byJoinedPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byJoinedPath) Less(i, j int) bool { return joinPath(a[i]) < joinPath(a[j]) }
@@ -0,0 +1,291 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestConvertJSONPlan(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_db_instance.db": {
+							Destroy: true,
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"engine":   {Old: "postgres", New: "mysql", RequiresNew: true},
+								"id":       {Old: "db-1", NewComputed: true},
+								"password": {Old: "old", New: "new", Sensitive: true},
+							},
+						},
+						"data.aws_ami.ubuntu": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": {NewComputed: true},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "network"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_subnet.a.0": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"tags.%":    {Old: "1", New: "0"},
+								"tags.Name": {Old: "a", NewRemoved: true},
+							},
+						},
+					},
+				},
+			},
+		},
+		State: &terraform.State{
+			Version:   3,
+			TFVersion: "0.7.13",
+			Modules: []*terraform.ModuleState{
+				{
+					Path: []string{"root"},
+					Outputs: map[string]*terraform.OutputState{
+						"address": {Type: "string", Value: "db.example.com"},
+					},
+					Resources: map[string]*terraform.ResourceState{
+						"aws_db_instance.db": {
+							Type:     "aws_db_instance",
+							Provider: "aws.west",
+							Primary: &terraform.InstanceState{
+								ID: "db-1",
+								Attributes: map[string]string{
+									"id":       "db-1",
+									"engine":   "postgres",
+									"password": "old",
+									"port":     "5432",
+								},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "network"},
+					Resources: map[string]*terraform.ResourceState{
+						"aws_subnet.a.0": {
+							Type: "aws_subnet",
+							Primary: &terraform.InstanceState{
+								ID: "subnet-1",
+								Attributes: map[string]string{
+									"id":        "subnet-1",
+									"tags.%":    "1",
+									"tags.Name": "a",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Vars: map[string]interface{}{
+			"region": "us-east-1",
+		},
+	}
+
+	j, err := Marshal(ConvertJSONPlan(plan, Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != expectedJSONPlan {
+		t.Errorf("Expected: %s\nActual: %s", expectedJSONPlan, j)
+	}
+
+	// Reading the JSON plan must give back the same changes
+	j, err = Marshal(ConvertJSONPlan(plan, Options{ShowSensitive: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRawPlan(bytes.NewReader(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Summarize(read.Diff), Summarize(plan.Diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %+v\nActual: %+v", want, got)
+	}
+}
+
+const expectedJSONPlan = `{
+    "format_version": "1.0",
+    "terraform_version": "0.7.13",
+    "variables": {
+        "region": {
+            "value": "us-east-1"
+        }
+    },
+    "resource_changes": [
+        {
+            "address": "aws_db_instance.db",
+            "mode": "managed",
+            "type": "aws_db_instance",
+            "name": "db",
+            "provider_name": "aws.west",
+            "change": {
+                "actions": [
+                    "delete",
+                    "create"
+                ],
+                "before": {
+                    "engine": "postgres",
+                    "id": "db-1",
+                    "password": "<sensitive>",
+                    "port": "5432"
+                },
+                "after": {
+                    "engine": "mysql",
+                    "password": "<sensitive>",
+                    "port": "5432"
+                },
+                "after_unknown": {
+                    "id": true
+                },
+                "before_sensitive": {
+                    "password": true
+                },
+                "after_sensitive": {
+                    "password": true
+                },
+                "replace_paths": [
+                    [
+                        "engine"
+                    ]
+                ]
+            }
+        },
+        {
+            "address": "data.aws_ami.ubuntu",
+            "mode": "data",
+            "type": "aws_ami",
+            "name": "ubuntu",
+            "provider_name": "aws",
+            "change": {
+                "actions": [
+                    "read"
+                ],
+                "before": {},
+                "after": {},
+                "after_unknown": {
+                    "id": true
+                },
+                "before_sensitive": {},
+                "after_sensitive": {}
+            }
+        },
+        {
+            "address": "module.network.aws_subnet.a[0]",
+            "module_address": "module.network",
+            "mode": "managed",
+            "type": "aws_subnet",
+            "name": "a",
+            "index": 0,
+            "provider_name": "aws",
+            "change": {
+                "actions": [
+                    "update"
+                ],
+                "before": {
+                    "id": "subnet-1",
+                    "tags": {
+                        "Name": "a"
+                    }
+                },
+                "after": {
+                    "id": "subnet-1",
+                    "tags": {}
+                },
+                "after_unknown": {
+                    "tags": {}
+                },
+                "before_sensitive": {
+                    "tags": {}
+                },
+                "after_sensitive": {
+                    "tags": {}
+                }
+            }
+        }
+    ],
+    "prior_state": {
+        "format_version": "1.0",
+        "terraform_version": "0.7.13",
+        "values": {
+            "outputs": {
+                "address": {
+                    "sensitive": false,
+                    "value": "db.example.com"
+                }
+            },
+            "root_module": {
+                "resources": [
+                    {
+                        "address": "aws_db_instance.db",
+                        "mode": "managed",
+                        "type": "aws_db_instance",
+                        "name": "db",
+                        "provider_name": "aws.west",
+                        "values": {
+                            "engine": "postgres",
+                            "id": "db-1",
+                            "password": "<sensitive>",
+                            "port": "5432"
+                        },
+                        "sensitive_values": {
+                            "password": true
+                        }
+                    }
+                ],
+                "child_modules": [
+                    {
+                        "address": "module.network",
+                        "resources": [
+                            {
+                                "address": "module.network.aws_subnet.a[0]",
+                                "mode": "managed",
+                                "type": "aws_subnet",
+                                "name": "a",
+                                "index": 0,
+                                "provider_name": "aws",
+                                "values": {
+                                    "id": "subnet-1",
+                                    "tags": {
+                                        "Name": "a"
+                                    }
+                                }
+                            }
+                        ]
+                    }
+                ]
+            }
+        }
+    }
+}`
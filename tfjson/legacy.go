@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Legacy is the unversioned nested representation of a plan produced by
+// earlier releases, in which module names, resource keys and attribute keys
+// share one namespace.
+type Legacy map[string]interface{}
+
+// ConvertLegacy returns the legacy representation of plan. It keeps the layout
+// of earlier releases, so attributes are emitted as their new values, but
+// sensitive values are replaced with SensitivePlaceholder unless
+// opts.ShowSensitive is set.
+func ConvertLegacy(plan *terraform.Plan, opts Options) Legacy {
+	diff := Legacy{}
+	for _, v := range plan.Diff.Modules {
+		insertModuleDiff(diff, v, opts)
+	}
+	return diff
+}
+
+func insert(out Legacy, path []string, key string, value interface{}) {
+	if len(path) > 0 && path[0] == "root" {
+		path = path[1:]
+	}
+	for _, elem := range path {
+		switch nested := out[elem].(type) {
+		case Legacy:
+			out = nested
+		default:
+			new := Legacy{}
+			out[elem] = new
+			out = new
+		}
+	}
+	out[key] = value
+}
+
+func insertModuleDiff(out Legacy, diff *terraform.ModuleDiff, opts Options) {
+	insert(out, diff.Path, "destroy", diff.Destroy)
+	for k, v := range diff.Resources {
+		insertInstanceDiff(out, append(diff.Path, k), v, opts)
+	}
+}
+
+func insertInstanceDiff(out Legacy, path []string, diff *terraform.InstanceDiff, opts Options) {
+	insert(out, path, "destroy", diff.Destroy)
+	insert(out, path, "destroy_tainted", diff.DestroyTainted)
+	for k, v := range diff.Attributes {
+		if v.Sensitive && !opts.ShowSensitive {
+			insert(out, path, k, SensitivePlaceholder)
+			continue
+		}
+		insert(out, path, k, v.New)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestConvertLegacy(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_lambda_permission.p": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"action": {New: "lambda:InvokeFunction"},
+								"secret": {Old: "a", New: "b", Sensitive: true},
+								"arn":    {NewComputed: true},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "inner"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_vpc.inner": {Destroy: true},
+					},
+				},
+			},
+		},
+	}
+
+	want := Legacy{
+		"destroy": false,
+		"aws_lambda_permission.p": Legacy{
+			"destroy":         false,
+			"destroy_tainted": false,
+			"action":          "lambda:InvokeFunction",
+			"secret":          SensitivePlaceholder,
+			"arn":             "",
+		},
+		"inner": Legacy{
+			"destroy": false,
+			"aws_vpc.inner": Legacy{
+				"destroy":         true,
+				"destroy_tainted": false,
+			},
+		},
+	}
+	if got := ConvertLegacy(plan, Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %v\nActual: %v", want, got)
+	}
+
+	want["aws_lambda_permission.p"].(Legacy)["secret"] = "b"
+	if got := ConvertLegacy(plan, Options{ShowSensitive: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %v\nActual: %v", want, got)
+	}
+}
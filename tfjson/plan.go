@@ -0,0 +1,436 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package tfjson converts Terraform plans into a structured representation
+// that can be consumed directly or encoded as JSON.
+package tfjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// SensitivePlaceholder replaces the values of sensitive attributes.
+const SensitivePlaceholder = "<sensitive>"
+
+// Options controls how a plan is converted.
+type Options struct {
+	// Detailed emits each attribute as an AttributeDiff instead of just its
+	// new value.
+	Detailed bool
+
+	// ShowSensitive emits the values of attributes marked sensitive by the
+	// provider instead of SensitivePlaceholder.
+	ShowSensitive bool
+
+	// Expand rebuilds flattened attribute keys such as "tags.Name" into
+	// nested maps and lists.
+	Expand bool
+
+	// State includes the state the plan was created against.
+	State bool
+
+	// Variables includes the variables the plan was created with.
+	Variables bool
+
+	// RedactVariables lists patterns, in the syntax of path.Match, for the
+	// names of variables whose values are replaced by SensitivePlaceholder
+	// unless ShowSensitive is set. Matching ignores case. If nil,
+	// DefaultRedactVariables is used; an empty slice redacts nothing.
+	RedactVariables []string
+
+	// Targets includes the resource addresses the plan was targeted at.
+	Targets bool
+
+	// Config includes the configuration of every module, as embedded in the
+	// plan.
+	Config bool
+}
+
+// DefaultRedactVariables are the patterns for the names of variables that are
+// redacted by default.
+var DefaultRedactVariables = []string{"*password*", "*secret*", "*token*", "*key*"}
+
+// FormatVersion is the version of the output format described by Plan. The
+// major version is incremented whenever a change is not backwards compatible.
+const FormatVersion = "1.0"
+
+// Plan is the structured representation of a Terraform plan.
+//
+// State, Variables, Targets and Config are only set if requested by Options,
+// and Targets is omitted if the plan was not targeted.
+type Plan struct {
+	FormatVersion string                 `json:"format_version"`
+	Summary       *Summary               `json:"summary"`
+	Modules       []*Module              `json:"modules"`
+	Reads         []*Read                `json:"reads"`
+	State         *State                 `json:"state,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Targets       []string               `json:"targets,omitempty"`
+	Config        []*ModuleConfig        `json:"config,omitempty"`
+}
+
+// Module is the diff of a single module, identified by its path from the
+// root module.
+type Module struct {
+	Path      []string    `json:"path"`
+	Action    string      `json:"action"`
+	Destroy   bool        `json:"destroy"`
+	Resources []*Resource `json:"resources"`
+}
+
+// Resource is the diff of a single resource instance. Name is the key of the
+// instance within its module, such as "aws_instance.web.0".
+//
+// Mode is "managed" or "data". The diff of a data source that is not being
+// destroyed has the action "read" and is read when the plan is applied, as
+// indicated by ReadAt.
+type Resource struct {
+	Name           string                 `json:"name"`
+	Mode           string                 `json:"mode"`
+	ReadAt         string                 `json:"read_at,omitempty"`
+	Action         string                 `json:"action"`
+	Destroy        bool                   `json:"destroy"`
+	DestroyTainted bool                   `json:"destroy_tainted"`
+	Attributes     map[string]interface{} `json:"attributes"`
+}
+
+// Read is a data source read by Terraform. ReadAt is "plan" if the data
+// source was read while the plan was created, in which case its values are
+// part of the state, and "apply" if reading it was deferred until the plan
+// is applied because its configuration depends on values that are not yet
+// known.
+type Read struct {
+	Path   []string `json:"path"`
+	Name   string   `json:"name"`
+	ReadAt string   `json:"read_at"`
+}
+
+// AttributeDiff is the detailed representation of a single attribute.
+type AttributeDiff struct {
+	Old         string `json:"old"`
+	New         string `json:"new"`
+	NewComputed bool   `json:"new_computed"`
+	NewRemoved  bool   `json:"new_removed"`
+	RequiresNew bool   `json:"requires_new"`
+}
+
+// ReadPlan reads a Terraform plan file from r and converts it.
+func ReadPlan(r io.Reader, opts Options) (*Plan, error) {
+	plan, err := ReadRawPlan(r)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertPlan(plan, opts), nil
+}
+
+// ConvertPlan converts a Terraform plan.
+func ConvertPlan(plan *terraform.Plan, opts Options) *Plan {
+	out := &Plan{
+		FormatVersion: FormatVersion,
+		Summary:       Summarize(plan.Diff),
+		Modules:       []*Module{},
+	}
+	for _, v := range plan.Diff.Modules {
+		out.Modules = append(out.Modules, convertModuleDiff(v, opts))
+	}
+	sort.Sort(byPath(out.Modules))
+	out.Reads = convertReads(plan)
+
+	if opts.State && plan.State != nil {
+		out.State = convertState(plan.State, sensitiveAttributes(plan), opts)
+	}
+	if opts.Variables {
+		out.Variables = convertVariables(plan.Vars, opts)
+	}
+	if opts.Targets {
+		out.Targets = plan.Targets
+	}
+	if opts.Config && plan.Module != nil {
+		out.Config = ConvertModuleTree(plan.Module, opts)
+	}
+	return out
+}
+
+// Marshal returns the indented JSON encoding of v. Unlike json.MarshalIndent
+// it does not escape HTML characters, which would mangle placeholders such as
+// SensitivePlaceholder.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func convertModuleDiff(diff *terraform.ModuleDiff, opts Options) *Module {
+	out := &Module{
+		Path:      diff.Path,
+		Action:    moduleAction(diff),
+		Destroy:   diff.Destroy,
+		Resources: []*Resource{},
+	}
+	for k, v := range diff.Resources {
+		out.Resources = append(out.Resources, convertInstanceDiff(k, v, opts))
+	}
+	sort.Sort(byName(out.Resources))
+	return out
+}
+
+func convertInstanceDiff(name string, diff *terraform.InstanceDiff, opts Options) *Resource {
+	out := &Resource{
+		Name:           name,
+		Mode:           resourceMode(keyMode(name)),
+		Action:         instanceAction(keyMode(name), diff),
+		Destroy:        diff.Destroy,
+		DestroyTainted: diff.DestroyTainted,
+		Attributes:     convertAttributes(diff.Attributes, opts),
+	}
+	if out.Action == "read" {
+		out.ReadAt = "apply"
+	}
+	return out
+}
+
+// convertReads returns the data sources read by a plan. Data sources in the
+// diff are read on apply; those only in the state were read during planning.
+func convertReads(plan *terraform.Plan) []*Read {
+	out := []*Read{}
+	for _, m := range plan.Diff.Modules {
+		for k, d := range m.Resources {
+			if instanceAction(keyMode(k), d) == "read" {
+				out = append(out, &Read{Path: m.Path, Name: k, ReadAt: "apply"})
+			}
+		}
+	}
+	if plan.State != nil {
+		for _, m := range plan.State.Modules {
+			md := plan.Diff.ModuleByPath(m.Path)
+			for k := range m.Resources {
+				if keyMode(k) != config.DataResourceMode {
+					continue
+				}
+				if md != nil && md.Resources[k] != nil {
+					continue
+				}
+				out = append(out, &Read{Path: m.Path, Name: k, ReadAt: "plan"})
+			}
+		}
+	}
+	sort.Sort(byReadAddress(out))
+	return out
+}
+
+// sensitiveAttributes returns the keys of the attributes that the diff or the
+// state of plan marks sensitive, by the address of their instance.
+func sensitiveAttributes(plan *terraform.Plan) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	mark := func(addr *terraform.ResourceAddress, name string) {
+		if out[addr.String()] == nil {
+			out[addr.String()] = make(map[string]bool)
+		}
+		out[addr.String()][name] = true
+	}
+	for _, m := range plan.Diff.Modules {
+		for k, d := range m.Resources {
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil {
+				continue
+			}
+			for name, attr := range d.Attributes {
+				if attr.Sensitive {
+					mark(addr, name)
+				}
+			}
+		}
+	}
+	if plan.State == nil {
+		return out
+	}
+	for _, m := range plan.State.Modules {
+		for k, r := range m.Resources {
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil || r.Primary == nil {
+				continue
+			}
+			paths := sensitivePaths(r.Primary)
+			for name := range r.Primary.Attributes {
+				if covered(name, paths) {
+					mark(addr, name)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func convertVariables(vars map[string]interface{}, opts Options) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range vars {
+		out[k] = redactVariable(k, v, opts)
+	}
+	return out
+}
+
+// redactVariable returns SensitivePlaceholder if the value of the variable
+// called name is to be redacted, and value otherwise.
+func redactVariable(name string, value interface{}, opts Options) interface{} {
+	patterns := opts.RedactVariables
+	if patterns == nil {
+		patterns = DefaultRedactVariables
+	}
+	if !opts.ShowSensitive && matchAny(patterns, name) {
+		return SensitivePlaceholder
+	}
+	return value
+}
+
+// matchAny returns true if name matches any of patterns, ignoring case.
+// Malformed patterns never match.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceAction returns the name of the action Terraform takes for an
+// instance with the given mode. Diffs of data sources are not changes to
+// infrastructure, so they are reported as "read", or as "forget" if the data
+// source is only being removed from the state.
+func instanceAction(mode config.ResourceMode, d *terraform.InstanceDiff) string {
+	if mode == config.DataResourceMode && !d.Empty() {
+		if d.GetDestroy() {
+			return "forget"
+		}
+		return "read"
+	}
+	return action(d.ChangeType())
+}
+
+// moduleAction returns the name of the action Terraform takes for a module.
+// Like ModuleDiff.ChangeType it is "create" or "destroy" if every changed
+// resource is created or destroyed respectively, and "update" for any other
+// mix of changes, but it disregards data sources unless the module changes
+// nothing else.
+func moduleAction(diff *terraform.ModuleDiff) string {
+	result := "no-op"
+	for k, d := range diff.Resources {
+		switch a := instanceAction(keyMode(k), d); a {
+		case "create", "destroy":
+			switch result {
+			case "no-op", "read", "forget":
+				result = a
+			case a:
+			default:
+				result = "update"
+			}
+		case "update", "replace":
+			result = "update"
+		case "read":
+			if result == "no-op" || result == "forget" {
+				result = "read"
+			}
+		case "forget":
+			if result == "no-op" {
+				result = "forget"
+			}
+		}
+	}
+	return result
+}
+
+// keyMode returns the mode of the instance with the given key in a module
+// diff or state.
+func keyMode(key string) config.ResourceMode {
+	if k, err := terraform.ParseResourceStateKey(key); err == nil {
+		return k.Mode
+	}
+	return config.ManagedResourceMode
+}
+
+// action returns the name of the action Terraform takes for a change type.
+// DiffDestroyCreate is reported as "replace" since the resource may also be
+// created before it is destroyed.
+func action(t terraform.DiffChangeType) string {
+	switch t {
+	case terraform.DiffNone:
+		return "no-op"
+	case terraform.DiffCreate:
+		return "create"
+	case terraform.DiffUpdate:
+		return "update"
+	case terraform.DiffDestroy:
+		return "destroy"
+	case terraform.DiffDestroyCreate:
+		return "replace"
+	default:
+		return "invalid"
+	}
+}
+
+// pathLess orders module paths, placing each module before its children.
+func pathLess(a, b []string) bool {
+	return strings.Join(a, ".") < strings.Join(b, ".")
+}
+
+func pathEqual(a, b []string) bool {
+	return strings.Join(a, ".") == strings.Join(b, ".")
+}
+
+// byPath sorts modules by path.
+type byPath []*Module
+
+func (a byPath) Len() int           { return len(a) }
+func (a byPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPath) Less(i, j int) bool { return pathLess(a[i].Path, a[j].Path) }
+
+// byName sorts resources by name.
+type byName []*Resource
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// byReadAddress sorts reads by module path and then by name.
+type byReadAddress []*Read
+
+func (a byReadAddress) Len() int      { return len(a) }
+func (a byReadAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byReadAddress) Less(i, j int) bool {
+	if !pathEqual(a[i].Path, a[j].Path) {
+		return pathLess(a[i].Path, a[j].Path)
+	}
+	return a[i].Name < a[j].Name
+}
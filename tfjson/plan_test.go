@@ -0,0 +1,626 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const expectedDetailed = `{
+    "format_version": "1.0",
+    "summary": {
+        "empty": false,
+        "add": 0,
+        "change": 0,
+        "replace": 1,
+        "destroy": 0,
+        "read": 0,
+        "modules": [
+            {
+                "path": [
+                    "root"
+                ],
+                "action": "update",
+                "add": 0,
+                "change": 0,
+                "replace": 1,
+                "destroy": 0,
+                "read": 0
+            }
+        ],
+        "types": {
+            "aws_instance": {
+                "add": 0,
+                "change": 0,
+                "replace": 1,
+                "destroy": 0,
+                "read": 0
+            }
+        }
+    },
+    "modules": [
+        {
+            "path": [
+                "root"
+            ],
+            "action": "update",
+            "destroy": false,
+            "resources": [
+                {
+                    "name": "aws_instance.web",
+                    "mode": "managed",
+                    "action": "replace",
+                    "destroy": true,
+                    "destroy_tainted": false,
+                    "attributes": {
+                        "ami": {
+                            "old": "ami-1",
+                            "new": "ami-2",
+                            "new_computed": false,
+                            "new_removed": false,
+                            "requires_new": true
+                        },
+                        "private_ip": {
+                            "old": "",
+                            "new": "",
+                            "new_computed": true,
+                            "new_removed": false,
+                            "requires_new": false
+                        }
+                    }
+                }
+            ]
+        }
+    ],
+    "reads": []
+}`
+
+func TestDetailed(t *testing.T) {
+	plan := instancePlan("aws_instance.web", &terraform.InstanceDiff{
+		Destroy: true,
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami":        {Old: "ami-1", New: "ami-2", RequiresNew: true},
+			"private_ip": {NewComputed: true},
+		},
+	})
+
+	j := convert(t, plan, Options{Detailed: true})
+	if j != expectedDetailed {
+		t.Errorf("Expected: %s\nActual: %s", expectedDetailed, j)
+	}
+}
+
+const expectedSensitive = `{
+    "format_version": "1.0",
+    "summary": {
+        "empty": false,
+        "add": 0,
+        "change": 1,
+        "replace": 0,
+        "destroy": 0,
+        "read": 0,
+        "modules": [
+            {
+                "path": [
+                    "root"
+                ],
+                "action": "update",
+                "add": 0,
+                "change": 1,
+                "replace": 0,
+                "destroy": 0,
+                "read": 0
+            }
+        ],
+        "types": {
+            "aws_db_instance": {
+                "add": 0,
+                "change": 1,
+                "replace": 0,
+                "destroy": 0,
+                "read": 0
+            }
+        }
+    },
+    "modules": [
+        {
+            "path": [
+                "root"
+            ],
+            "action": "update",
+            "destroy": false,
+            "resources": [
+                {
+                    "name": "aws_db_instance.db",
+                    "mode": "managed",
+                    "action": "update",
+                    "destroy": false,
+                    "destroy_tainted": false,
+                    "attributes": {
+                        "password": %q
+                    }
+                }
+            ]
+        }
+    ],
+    "reads": []
+}`
+
+func TestSensitive(t *testing.T) {
+	plan := instancePlan("aws_db_instance.db", &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"password": {Old: "hunter1", New: "hunter2", Sensitive: true},
+		},
+	})
+
+	for _, tc := range []struct {
+		showSensitive bool
+		password      string
+	}{
+		{false, "<sensitive>"},
+		{true, "hunter2"},
+	} {
+		j := convert(t, plan, Options{ShowSensitive: tc.showSensitive})
+		if want := fmt.Sprintf(expectedSensitive, tc.password); j != want {
+			t.Errorf("Expected: %s\nActual: %s", want, j)
+		}
+	}
+}
+
+const expectedExpand = `{
+    "format_version": "1.0",
+    "summary": {
+        "empty": false,
+        "add": 1,
+        "change": 0,
+        "replace": 0,
+        "destroy": 0,
+        "read": 0,
+        "modules": [
+            {
+                "path": [
+                    "root"
+                ],
+                "action": "create",
+                "add": 1,
+                "change": 0,
+                "replace": 0,
+                "destroy": 0,
+                "read": 0
+            }
+        ],
+        "types": {
+            "aws_instance": {
+                "add": 1,
+                "change": 0,
+                "replace": 0,
+                "destroy": 0,
+                "read": 0
+            }
+        }
+    },
+    "modules": [
+        {
+            "path": [
+                "root"
+            ],
+            "action": "create",
+            "destroy": false,
+            "resources": [
+                {
+                    "name": "aws_instance.web",
+                    "mode": "managed",
+                    "action": "create",
+                    "destroy": false,
+                    "destroy_tainted": false,
+                    "attributes": {
+                        "ami": "ami-1",
+                        "ebs_block_device": [
+                            {
+                                "device_name": "/dev/sdb"
+                            },
+                            {
+                                "device_name": "/dev/sdc"
+                            }
+                        ],
+                        "security_groups": "74D93920-ED26-11E3-AC10-0800200C9A66",
+                        "tags": {
+                            "Name": "web",
+                            "Team": "infra"
+                        }
+                    }
+                }
+            ]
+        }
+    ],
+    "reads": []
+}`
+
+func TestExpand(t *testing.T) {
+	plan := instancePlan("aws_instance.web", &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami":                               {New: "ami-1", RequiresNew: true},
+			"ebs_block_device.#":                {New: "2"},
+			"ebs_block_device.2087.device_name": {New: "/dev/sdc"},
+			"ebs_block_device.1234.device_name": {New: "/dev/sdb"},
+			"security_groups.#":                 {New: "74D93920-ED26-11E3-AC10-0800200C9A66"},
+			"tags.%":                            {New: "2"},
+			"tags.Name":                         {New: "web"},
+			"tags.Team":                         {New: "infra"},
+		},
+	})
+
+	j := convert(t, plan, Options{Expand: true})
+	if j != expectedExpand {
+		t.Errorf("Expected: %s\nActual: %s", expectedExpand, j)
+	}
+}
+
+const expectedStructured = `{
+    "format_version": "1.0",
+    "summary": {
+        "empty": false,
+        "add": 0,
+        "change": 1,
+        "replace": 0,
+        "destroy": 1,
+        "read": 0,
+        "modules": [
+            {
+                "path": [
+                    "root"
+                ],
+                "action": "destroy",
+                "add": 0,
+                "change": 0,
+                "replace": 0,
+                "destroy": 1,
+                "read": 0
+            },
+            {
+                "path": [
+                    "root",
+                    "destroy"
+                ],
+                "action": "update",
+                "add": 0,
+                "change": 1,
+                "replace": 0,
+                "destroy": 0,
+                "read": 0
+            }
+        ],
+        "types": {
+            "aws_instance": {
+                "add": 0,
+                "change": 1,
+                "replace": 0,
+                "destroy": 1,
+                "read": 0
+            }
+        }
+    },
+    "modules": [
+        {
+            "path": [
+                "root"
+            ],
+            "action": "destroy",
+            "destroy": false,
+            "resources": [
+                {
+                    "name": "aws_instance.web",
+                    "mode": "managed",
+                    "action": "destroy",
+                    "destroy": true,
+                    "destroy_tainted": false,
+                    "attributes": {}
+                }
+            ]
+        },
+        {
+            "path": [
+                "root",
+                "destroy"
+            ],
+            "action": "update",
+            "destroy": false,
+            "resources": [
+                {
+                    "name": "aws_instance.web",
+                    "mode": "managed",
+                    "action": "update",
+                    "destroy": false,
+                    "destroy_tainted": false,
+                    "attributes": {
+                        "destroy": "true"
+                    }
+                }
+            ]
+        }
+    ],
+    "reads": []
+}`
+
+func TestStructured(t *testing.T) {
+	// A module or attribute named "destroy" must not clobber the destroy
+	// flags of the module or resource.
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root", "destroy"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"destroy": {Old: "false", New: "true"},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": {Destroy: true},
+					},
+				},
+			},
+		},
+	}
+
+	j := convert(t, plan, Options{})
+	if j != expectedStructured {
+		t.Errorf("Expected: %s\nActual: %s", expectedStructured, j)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{},
+		Vars: map[string]interface{}{
+			"region":      "us-east-1",
+			"DB_Password": "hunter2",
+			"api_token":   "abc",
+		},
+	}
+
+	for _, tc := range []struct {
+		opts Options
+		want map[string]interface{}
+	}{
+		{
+			Options{Variables: true},
+			map[string]interface{}{"region": "us-east-1", "DB_Password": "<sensitive>", "api_token": "<sensitive>"},
+		},
+		{
+			Options{Variables: true, RedactVariables: []string{"region"}},
+			map[string]interface{}{"region": "<sensitive>", "DB_Password": "hunter2", "api_token": "abc"},
+		},
+		{
+			Options{Variables: true, ShowSensitive: true},
+			map[string]interface{}{"region": "us-east-1", "DB_Password": "hunter2", "api_token": "abc"},
+		},
+		{
+			Options{},
+			nil,
+		},
+	} {
+		if got := ConvertPlan(plan, tc.opts).Variables; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: expected %v, got %v", tc.opts, tc.want, got)
+		}
+	}
+}
+
+func TestStateAndTargets(t *testing.T) {
+	plan := instancePlan("aws_db_instance.db", &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"password": {Old: "hunter1", New: "hunter2", Sensitive: true},
+		},
+	})
+	plan.Targets = []string{"aws_db_instance.db"}
+	plan.State = &terraform.State{
+		Version: 3,
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_db_instance.db": {
+						Type: "aws_db_instance",
+						Primary: &terraform.InstanceState{
+							ID:         "db-1",
+							Attributes: map[string]string{"id": "db-1", "password": "hunter1"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		opts     Options
+		password string
+	}{
+		{Options{State: true, Targets: true}, "<sensitive>"},
+		{Options{State: true, Targets: true, ShowSensitive: true}, "hunter1"},
+	} {
+		out := ConvertPlan(plan, tc.opts)
+		if !reflect.DeepEqual(out.Targets, plan.Targets) {
+			t.Errorf("%+v: expected targets %v, got %v", tc.opts, plan.Targets, out.Targets)
+		}
+		if out.State == nil || len(out.State.Modules) != 1 || len(out.State.Modules[0].Resources) != 1 {
+			t.Fatalf("%+v: unexpected state %+v", tc.opts, out.State)
+		}
+		want := map[string]interface{}{"id": "db-1", "password": tc.password}
+		if got := out.State.Modules[0].Resources[0].Primary.Attributes; !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: expected %v, got %v", tc.opts, want, got)
+		}
+	}
+
+	if out := ConvertPlan(plan, Options{}); out.State != nil || out.Targets != nil {
+		t.Errorf("Expected no state or targets, got %+v and %v", out.State, out.Targets)
+	}
+}
+
+func TestReads(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"data.aws_ami.ubuntu": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id":     {NewComputed: true, RequiresNew: true},
+								"filter": {New: "ubuntu-*"},
+							},
+						},
+						"data.aws_ami.old": {Destroy: true},
+						"aws_instance.web": {Destroy: true},
+					},
+				},
+				{
+					Path: []string{"root", "lookup"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"data.aws_vpc.selected": {
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": {NewComputed: true, RequiresNew: true},
+							},
+						},
+					},
+				},
+				{
+					Path: []string{"root", "old"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"data.aws_vpc.old": {Destroy: true},
+					},
+				},
+			},
+		},
+		State: &terraform.State{
+			Modules: []*terraform.ModuleState{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.ResourceState{
+						"data.aws_ami.old":              {Type: "aws_ami"},
+						"data.aws_availability_zones.a": {Type: "aws_availability_zones"},
+						"aws_instance.web":              {Type: "aws_instance"},
+					},
+				},
+				{
+					Path: []string{"root", "network"},
+					Resources: map[string]*terraform.ResourceState{
+						"data.aws_vpc.default": {Type: "aws_vpc"},
+					},
+				},
+			},
+		},
+	}
+
+	out := ConvertPlan(plan, Options{})
+	wantReads := []*Read{
+		{Path: []string{"root"}, Name: "data.aws_ami.ubuntu", ReadAt: "apply"},
+		{Path: []string{"root"}, Name: "data.aws_availability_zones.a", ReadAt: "plan"},
+		{Path: []string{"root", "lookup"}, Name: "data.aws_vpc.selected", ReadAt: "apply"},
+		{Path: []string{"root", "network"}, Name: "data.aws_vpc.default", ReadAt: "plan"},
+	}
+	if !reflect.DeepEqual(out.Reads, wantReads) {
+		gotJSON, _ := Marshal(out.Reads)
+		wantJSON, _ := Marshal(wantReads)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+
+	for _, tc := range []struct {
+		name, mode, action, readAt string
+	}{
+		{"aws_instance.web", "managed", "destroy", ""},
+		{"data.aws_ami.old", "data", "forget", ""},
+		{"data.aws_ami.ubuntu", "data", "read", "apply"},
+	} {
+		var r *Resource
+		for _, v := range out.Modules[0].Resources {
+			if v.Name == tc.name {
+				r = v
+			}
+		}
+		if r == nil {
+			t.Errorf("%s: missing", tc.name)
+			continue
+		}
+		if r.Mode != tc.mode || r.Action != tc.action || r.ReadAt != tc.readAt {
+			t.Errorf("%s: expected %s %s %q, got %s %s %q", tc.name, tc.mode, tc.action, tc.readAt, r.Mode, r.Action, r.ReadAt)
+		}
+	}
+
+	// Removing a data source from the state is not a destroy
+	if want := (Counts{Destroy: 1, Read: 2}); out.Summary.Counts != want {
+		t.Errorf("Expected: %+v\nActual: %+v", want, out.Summary.Counts)
+	}
+
+	for i, want := range []string{"destroy", "read", "forget"} {
+		if m := out.Modules[i]; m.Action != want || out.Summary.Modules[i].Action != want {
+			t.Errorf("%v: expected %s, got %s and %s", m.Path, want, m.Action, out.Summary.Modules[i].Action)
+		}
+	}
+}
+
+// instancePlan returns a plan whose only change is diff to the resource with
+// the given key in the root module.
+func instancePlan(key string, diff *terraform.InstanceDiff) *terraform.Plan {
+	return &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						key: diff,
+					},
+				},
+			},
+		},
+	}
+}
+
+// convert round-trips plan through the plan file format, converts it and
+// returns the JSON encoding of the result.
+func convert(t *testing.T, plan *terraform.Plan, opts Options) string {
+	var buf bytes.Buffer
+	if err := terraform.WritePlan(plan, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ReadPlan(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(j)
+}
@@ -0,0 +1,276 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Policy is a set of rules that the changes made by a plan must not break.
+type Policy struct {
+	Rules []*Rule `hcl:"rule"`
+}
+
+// Rule describes changes that a plan must not make. A resource instance
+// violates a rule if it matches every condition the rule sets. A rule that
+// sets no conditions is violated by every changed instance.
+type Rule struct {
+	// Name identifies the rule in violations.
+	Name string `hcl:",key"`
+
+	// Description explains the rule to whoever violates it.
+	Description string `hcl:"description"`
+
+	// Resources are address patterns in the syntax of ParseFilter. If set,
+	// only instances matching at least one of them can violate the rule.
+	// Unlike FilterDiff, a pattern also matches instances in descendants of
+	// the module it names, so "aws_db_instance" matches databases in every
+	// module and "module.core" matches everything in module.core and below.
+	Resources []string `hcl:"resources"`
+
+	// Actions are the actions, such as "destroy" or "replace", that violate
+	// the rule. If not set any action does.
+	Actions []string `hcl:"actions"`
+
+	// Attribute is a path.Match pattern for flattened attribute keys, such
+	// as "ingress.*.cidr_blocks.*". If set, only instances that change a
+	// matching attribute violate the rule.
+	Attribute string `hcl:"attribute"`
+
+	// Value restricts Attribute to attributes whose planned new value is
+	// exactly Value.
+	Value string `hcl:"value"`
+
+	// Condition is a HIL expression in the syntax of ParseCondition. If set,
+	// only instances for which it holds violate the rule.
+	Condition string `hcl:"condition"`
+
+	addrs []*terraform.ResourceAddress
+	cond  *Condition
+}
+
+// Violation is a resource instance whose planned change violates a rule.
+type Violation struct {
+	Rule        string   `json:"rule"`
+	Description string   `json:"description"`
+	Address     string   `json:"address"`
+	Action      string   `json:"action"`
+	Attributes  []string `json:"attributes"`
+}
+
+// ReadPolicy reads a policy written in HCL or JSON, such as:
+//
+//	rule "no-database-destroy" {
+//	  description = "Databases must never be destroyed"
+//	  resources   = ["aws_db_instance"]
+//	  actions     = ["destroy", "replace"]
+//	}
+func ReadPolicy(r io.Reader) (*Policy, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := hcl.Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPolicyKeys(root); err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := hcl.DecodeObject(&p, root); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range p.Rules {
+		for _, s := range rule.Resources {
+			addr, err := ParseFilter(s)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %s", rule.Name, err)
+			}
+			rule.addrs = append(rule.addrs, addr)
+		}
+		for _, a := range rule.Actions {
+			switch a {
+			case "create", "update", "destroy", "replace", "read", "forget":
+			default:
+				return nil, fmt.Errorf("rule %q: unknown action %q", rule.Name, a)
+			}
+		}
+		if _, err := path.Match(rule.Attribute, ""); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid attribute pattern %q: %s", rule.Name, rule.Attribute, err)
+		}
+		if rule.Condition != "" {
+			if rule.cond, err = ParseCondition(rule.Condition); err != nil {
+				return nil, fmt.Errorf("rule %q: %s", rule.Name, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// ruleKeys are the keys a rule may set.
+var ruleKeys = []string{"description", "resources", "actions", "attribute", "value", "condition"}
+
+// checkPolicyKeys returns an error if the policy in root sets a key that it
+// does not know, since a misspelt key would otherwise silently widen a rule.
+func checkPolicyKeys(root *ast.File) error {
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return fmt.Errorf("policy must be an object")
+	}
+	for _, item := range list.Items {
+		if key := item.Keys[0].Token.Value().(string); key != "rule" {
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+	for _, item := range list.Filter("rule").Items {
+		if len(item.Keys) != 1 {
+			return fmt.Errorf("rule must have exactly one name, found %d", len(item.Keys))
+		}
+		name := item.Keys[0].Token.Value().(string)
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			continue
+		}
+		for _, attr := range obj.List.Items {
+			if key := attr.Keys[0].Token.Value().(string); !contains(ruleKeys, key) {
+				return fmt.Errorf("rule %q: unknown key %q", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the violations of the policy by the changes in diff,
+// ordered by address and then by rule. It returns an error if a resource key
+// cannot be parsed or a rule's condition cannot be evaluated. Conditions see
+// sensitive values only if opts.ShowSensitive is set.
+func (p *Policy) Evaluate(diff *terraform.Diff, opts Options) ([]*Violation, error) {
+	out := []*Violation{}
+	for _, m := range diff.Modules {
+		for k, d := range m.Resources {
+			if d.Empty() {
+				continue
+			}
+			addr, err := InstanceAddress(m.Path, k)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", k, err)
+			}
+			for _, rule := range p.Rules {
+				v, err := rule.evaluate(addr, d, opts)
+				if err != nil {
+					return nil, fmt.Errorf("rule %q: %s", rule.Name, err)
+				}
+				if v != nil {
+					out = append(out, v)
+				}
+			}
+		}
+	}
+
+	sort.Sort(byViolation(out))
+	return out, nil
+}
+
+// evaluate returns the violation of the rule by the instance at addr, or nil
+// if the instance does not violate it.
+func (r *Rule) evaluate(addr *terraform.ResourceAddress, d *terraform.InstanceDiff, opts Options) (*Violation, error) {
+	if len(r.addrs) > 0 && !r.matches(addr) {
+		return nil, nil
+	}
+
+	a := instanceAction(addr.Mode, d)
+	if len(r.Actions) > 0 && !contains(r.Actions, a) {
+		return nil, nil
+	}
+
+	attrs := []string{}
+	if r.Attribute != "" {
+		for k, attr := range d.Attributes {
+			if match(r.Attribute, k) && (r.Value == "" || attr.New == r.Value) {
+				attrs = append(attrs, k)
+			}
+		}
+		if len(attrs) == 0 {
+			return nil, nil
+		}
+		sort.Strings(attrs)
+	}
+
+	if r.cond != nil {
+		holds, err := r.cond.holds(addr, d, opts)
+		if err != nil || !holds {
+			return nil, err
+		}
+	}
+
+	return &Violation{
+		Rule:        r.Name,
+		Description: r.Description,
+		Address:     addr.String(),
+		Action:      a,
+		Attributes:  attrs,
+	}, nil
+}
+
+// matches returns true if addr matches any of the rule's resource patterns
+// in their module or below it.
+func (r *Rule) matches(addr *terraform.ResourceAddress) bool {
+	for _, f := range r.addrs {
+		if matchAddressUnder(f, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// byViolation sorts violations by address and then by rule.
+type byViolation []*Violation
+
+func (a byViolation) Len() int      { return len(a) }
+func (a byViolation) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byViolation) Less(i, j int) bool {
+	if a[i].Address != a[j].Address {
+		return a[i].Address < a[j].Address
+	}
+	return a[i].Rule < a[j].Rule
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const testPolicy = `
+rule "no-database-destroy" {
+  description = "Databases must never be destroyed"
+  resources   = ["aws_db_instance"]
+  actions     = ["destroy", "replace"]
+}
+
+rule "no-open-ingress" {
+  resources = ["aws_security_group"]
+  attribute = "ingress.*.cidr_blocks.*"
+  value     = "0.0.0.0/0"
+}
+
+rule "no-small-databases" {
+  resources = ["aws_db_instance"]
+  condition = "${match("db.*.small", lookup(attributes, "instance_class", ""))}"
+}
+
+rule "core-replacement" {
+  description = "Replacing anything in module.core requires approval"
+  resources   = ["module.core"]
+  actions     = ["replace"]
+}
+`
+
+func TestEvaluatePolicy(t *testing.T) {
+	policy, err := ReadPolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_db_instance.db": {Destroy: true},
+					"aws_db_instance.replica": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"instance_class": {Old: "db.t2.micro", New: "db.t2.small"},
+						},
+					},
+					"aws_security_group.web": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ingress.1234.cidr_blocks.#": {New: "2"},
+							"ingress.1234.cidr_blocks.0": {New: "10.0.0.0/8"},
+							"ingress.1234.cidr_blocks.1": {New: "0.0.0.0/0"},
+						},
+					},
+				},
+			},
+			{
+				Path: []string{"root", "db"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_db_instance.b": {Destroy: true},
+				},
+			},
+			{
+				Path: []string{"root", "core", "inner"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_instance.worker": {
+						Destroy: true,
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": {Old: "ami-1", New: "ami-2", RequiresNew: true},
+						},
+					},
+				},
+			},
+			{
+				Path: []string{"root", "core"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_instance.app": {
+						Destroy: true,
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": {Old: "ami-1", New: "ami-2", RequiresNew: true},
+						},
+					},
+					"aws_instance.bastion": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"tags.Name": {Old: "old", New: "new"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	want := []*Violation{
+		{
+			Rule:        "no-database-destroy",
+			Description: "Databases must never be destroyed",
+			Address:     "aws_db_instance.db",
+			Action:      "destroy",
+			Attributes:  []string{},
+		},
+		{
+			Rule:       "no-small-databases",
+			Address:    "aws_db_instance.replica",
+			Action:     "update",
+			Attributes: []string{},
+		},
+		{
+			Rule:       "no-open-ingress",
+			Address:    "aws_security_group.web",
+			Action:     "update",
+			Attributes: []string{"ingress.1234.cidr_blocks.1"},
+		},
+		{
+			Rule:        "core-replacement",
+			Description: "Replacing anything in module.core requires approval",
+			Address:     "module.core.aws_instance.app",
+			Action:      "replace",
+			Attributes:  []string{},
+		},
+		{
+			Rule:        "core-replacement",
+			Description: "Replacing anything in module.core requires approval",
+			Address:     "module.core.module.inner.aws_instance.worker",
+			Action:      "replace",
+			Attributes:  []string{},
+		},
+		{
+			Rule:        "no-database-destroy",
+			Description: "Databases must never be destroyed",
+			Address:     "module.db.aws_db_instance.b",
+			Action:      "destroy",
+			Attributes:  []string{},
+		},
+	}
+	got, err := policy.Evaluate(diff, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %+v\nActual: %+v", want, got)
+	}
+}
+
+func TestReadPolicyErrors(t *testing.T) {
+	for _, policy := range []string{
+		`rule "a" { actions = ["delete"] }`,
+		`rule "a" { resources = ["aws_[instance"] }`,
+		`rule "a" { attribute = "tags.[" }`,
+		`rule "a" { condition = "${eq(type" }`,
+		`rule "a" { resource = ["aws_instance"] }`,
+		`rule "a" { conditon = "${eq(type, \"aws_instance\")}" }`,
+		`rules "a" {}`,
+		`rule "a" "b" { description = "x" }`,
+		`rule { description = "x" }`,
+		`{"rule": {"a": {"action": ["destroy"]}}}`,
+	} {
+		if _, err := ReadPolicy(strings.NewReader(policy)); err == nil {
+			t.Errorf("Expected error for %s", policy)
+		}
+	}
+}
+
+func TestEvaluatePolicyInvalidKey(t *testing.T) {
+	policy, err := ReadPolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_db_instance.db.primary": {Destroy: true},
+				},
+			},
+		},
+	}
+	if _, err := policy.Evaluate(diff, Options{}); err == nil {
+		t.Error("Expected error for an invalid resource key")
+	}
+}
@@ -0,0 +1,404 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// sensitiveMetaKey is the key of the instance metadata in which readShowPlan
+// records the attribute paths that the prior state marks sensitive, as a JSON
+// array, since Terraform 0.7 states do not mark attributes sensitive.
+const sensitiveMetaKey = "tfjson_sensitive_paths"
+
+// readShowPlan converts the JSON plan printed by "terraform show -json" to a
+// terraform.Plan, flattening attribute values as Terraform 0.7 does.
+func readShowPlan(b []byte) (*terraform.Plan, error) {
+	var doc JSONPlan
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{},
+		Vars: make(map[string]interface{}),
+	}
+	for k, v := range doc.Variables {
+		plan.Vars[k] = jsonValue(v.Value)
+	}
+
+	modules := make(map[string]*terraform.ModuleDiff)
+	for _, rc := range doc.ResourceChanges {
+		if rc.Deposed != "" || rc.Change == nil {
+			// Deposed objects have no equivalent in a 0.7 diff
+			continue
+		}
+		diff, err := convertResourceChange(rc)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", rc.Type, rc.Name, err)
+		}
+		if diff == nil {
+			continue
+		}
+
+		key, err := stateKey(rc.Mode, rc.Type, rc.Name, rc.Index)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := modules[rc.ModuleAddress]
+		if !ok {
+			m = &terraform.ModuleDiff{
+				Path:      showModulePath(rc.ModuleAddress),
+				Resources: make(map[string]*terraform.InstanceDiff),
+			}
+			modules[rc.ModuleAddress] = m
+			plan.Diff.Modules = append(plan.Diff.Modules, m)
+		}
+		m.Resources[key] = diff
+	}
+
+	if doc.PriorState != nil {
+		state, err := convertShowState(doc.PriorState)
+		if err != nil {
+			return nil, err
+		}
+		plan.State = state
+	}
+	return plan, nil
+}
+
+// convertResourceChange converts a resource change to an instance diff, or
+// returns nil if it makes no change.
+func convertResourceChange(rc *JSONResourceChange) (*terraform.InstanceDiff, error) {
+	var create, destroy bool
+	for _, a := range rc.Change.Actions {
+		switch a {
+		case "create":
+			create = true
+		case "read":
+			// Terraform 0.7 plans the read of a data source as an update
+		case "delete":
+			destroy = true
+		case "update", "no-op":
+		default:
+			return nil, fmt.Errorf("unknown action %q", a)
+		}
+	}
+
+	out := &terraform.InstanceDiff{
+		Destroy:        destroy,
+		DestroyTainted: destroy && rc.ActionReason == "replace_because_tainted",
+		Attributes:     make(map[string]*terraform.ResourceAttrDiff),
+	}
+	if destroy && !create {
+		return out, nil
+	}
+
+	before, after := flatten(rc.Change.Before), flatten(rc.Change.After)
+	unknown := trueLeaves(rc.Change.AfterUnknown)
+	sensitive := append(trueLeaves(rc.Change.BeforeSensitive), trueLeaves(rc.Change.AfterSensitive)...)
+	var replace []string
+	for _, p := range rc.Change.ReplacePaths {
+		replace = append(replace, joinPath(p))
+	}
+
+	keys := make(map[string]bool)
+	for _, m := range []map[string]string{before, after} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for _, k := range unknown {
+		if !hasKeyUnder(after, k) {
+			keys[k] = true
+		}
+	}
+
+	for k := range keys {
+		old, inBefore := before[k]
+		new, inAfter := after[k]
+		computed := covered(k, unknown)
+		if inBefore && inAfter && old == new && !computed {
+			continue
+		}
+		attr := &terraform.ResourceAttrDiff{
+			Old:         old,
+			New:         new,
+			NewComputed: computed,
+			NewRemoved:  inBefore && !inAfter && !computed,
+			RequiresNew: create && covered(k, replace),
+			Sensitive:   covered(k, sensitive),
+		}
+		if computed {
+			attr.New = ""
+		}
+		out.Attributes[k] = attr
+	}
+
+	if create {
+		// Terraform 0.7 marks a diff as creating an instance by an attribute
+		// requiring a new one, usually the computed id.
+		if !requiresNew(out) {
+			id, ok := out.Attributes["id"]
+			if !ok {
+				id = &terraform.ResourceAttrDiff{NewComputed: true}
+				out.Attributes["id"] = id
+			}
+			id.RequiresNew = true
+		}
+	}
+
+	if out.Empty() {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func requiresNew(d *terraform.InstanceDiff) bool {
+	for _, attr := range d.Attributes {
+		if attr.RequiresNew {
+			return true
+		}
+	}
+	return false
+}
+
+func convertShowState(s *JSONState) (*terraform.State, error) {
+	out := &terraform.State{
+		Version:   terraform.StateVersion,
+		TFVersion: s.TerraformVersion,
+	}
+	if s.Values == nil || s.Values.RootModule == nil {
+		return out, nil
+	}
+
+	var walk func(m *JSONModule) error
+	walk = func(m *JSONModule) error {
+		module := &terraform.ModuleState{
+			Path:      showModulePath(m.Address),
+			Outputs:   make(map[string]*terraform.OutputState),
+			Resources: make(map[string]*terraform.ResourceState),
+		}
+		for _, r := range m.Resources {
+			key, err := stateKey(r.Mode, r.Type, r.Name, r.Index)
+			if err != nil {
+				return err
+			}
+			attrs := flatten(r.Values)
+			meta := make(map[string]string)
+			if paths := trueLeaves(r.SensitiveValues); len(paths) > 0 {
+				b, err := json.Marshal(paths)
+				if err != nil {
+					return err
+				}
+				meta[sensitiveMetaKey] = string(b)
+			}
+			module.Resources[key] = &terraform.ResourceState{
+				Type:         r.Type,
+				Provider:     r.ProviderName,
+				Dependencies: r.DependsOn,
+				Primary: &terraform.InstanceState{
+					ID:         attrs["id"],
+					Attributes: attrs,
+					Meta:       meta,
+				},
+			}
+		}
+		out.Modules = append(out.Modules, module)
+		for _, child := range m.ChildModules {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(s.Values.RootModule); err != nil {
+		return nil, err
+	}
+
+	// Only root module outputs are recorded
+	for k, v := range s.Values.Outputs {
+		value := jsonValue(v.Value)
+		output := &terraform.OutputState{Sensitive: v.Sensitive, Value: value}
+		switch value.(type) {
+		case []interface{}:
+			output.Type = "list"
+		case map[string]interface{}:
+			output.Type = "map"
+		default:
+			output.Type = "string"
+			output.Value = flatten(value)[""]
+		}
+		out.Modules[0].Outputs[k] = output
+	}
+	return out, nil
+}
+
+// showModulePath converts a module address such as "module.a.module.b" to a
+// module path such as ["root", "a", "b"].
+func showModulePath(addr string) []string {
+	path := []string{"root"}
+	if addr == "" {
+		return path
+	}
+	return append(path, strings.Split(strings.TrimPrefix(addr, "module."), ".module.")...)
+}
+
+// stateKey returns the key of a resource instance within its module, such as
+// "aws_instance.web.0" or "data.aws_ami.ubuntu". Terraform 0.7 addresses only
+// have numeric indexes, so the string keys of for_each instances are an error.
+func stateKey(mode, typ, name string, index interface{}) (string, error) {
+	key := typ + "." + name
+	if mode == "data" {
+		key = "data." + key
+	}
+	switch i := index.(type) {
+	case json.Number:
+		key += "." + i.String()
+	case string:
+		return "", fmt.Errorf("%s[%q]: for_each instances cannot be represented as Terraform 0.7 resources", key, i)
+	}
+	return key, nil
+}
+
+// flatten flattens a JSON value into Terraform 0.7 attributes, in which lists
+// and maps are recorded by the keys "#" and "%" respectively. A primitive
+// value is returned with the key "".
+func flatten(v interface{}) map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		join := func(k string) string {
+			if prefix == "" {
+				return k
+			}
+			return prefix + "." + k
+		}
+		switch v := v.(type) {
+		case nil:
+		case map[string]interface{}:
+			if prefix != "" {
+				out[join("%")] = strconv.Itoa(len(v))
+			}
+			for k, e := range v {
+				walk(join(k), e)
+			}
+		case []interface{}:
+			out[join("#")] = strconv.Itoa(len(v))
+			for i, e := range v {
+				walk(join(strconv.Itoa(i)), e)
+			}
+		default:
+			out[prefix] = fmt.Sprint(v)
+		}
+	}
+	walk("", v)
+	return out
+}
+
+// trueLeaves returns the flattened keys of the leaves of v that are true,
+// such as the unknown values in the after_unknown of a resource change.
+func trueLeaves(v interface{}) []string {
+	var out []string
+	for k, leaf := range flatten(v) {
+		if leaf == "true" {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// sensitivePaths returns the attribute paths that readShowPlan recorded as
+// sensitive in the metadata of state.
+func sensitivePaths(state *terraform.InstanceState) []string {
+	var paths []string
+	if v, ok := state.Meta[sensitiveMetaKey]; ok {
+		json.Unmarshal([]byte(v), &paths)
+	}
+	return paths
+}
+
+// covered reports whether the attribute key is one of paths or within one
+// of them.
+func covered(key string, paths []string) bool {
+	for _, p := range paths {
+		if p == "" || key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKeyUnder(attrs map[string]string, path string) bool {
+	for k := range attrs {
+		if covered(k, []string{path}) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath joins the steps of an attribute path, such as ["tags", "Name"],
+// into a flattened key.
+func joinPath(steps []interface{}) string {
+	var parts []string
+	for _, s := range steps {
+		parts = append(parts, fmt.Sprint(s))
+	}
+	return strings.Join(parts, ".")
+}
+
+// jsonValue converts the numbers in a value decoded with UseNumber to
+// strings, as Terraform 0.7 records them.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for k, e := range v {
+			out[k] = jsonValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = jsonValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const testShowPlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.5.7",
+  "variables": {"region": {"value": "us-east-1"}, "count": {"value": 2}},
+  "resource_changes": [
+    {
+      "module_address": "module.app", "mode": "managed", "type": "aws_instance", "name": "web", "index": 0,
+      "change": {
+        "actions": ["create"], "before": null,
+        "after": {"ami": "ami-1", "tags": {"Name": "web"}},
+        "after_unknown": {"id": true, "tags": {}}
+      }
+    },
+    {
+      "mode": "managed", "type": "aws_db_instance", "name": "db",
+      "change": {
+        "actions": ["delete", "create"],
+        "before": {"id": "db-1", "engine": "postgres", "password": "old", "port": 5432},
+        "after": {"engine": "mysql", "password": "new", "port": 5432},
+        "after_unknown": {"id": true},
+        "after_sensitive": {"password": true},
+        "replace_paths": [["engine"]]
+      }
+    },
+    {
+      "mode": "managed", "type": "aws_vpc", "name": "main",
+      "change": {"actions": ["no-op"], "before": {"id": "vpc-1"}, "after": {"id": "vpc-1"}, "after_unknown": {}}
+    },
+    {
+      "mode": "data", "type": "aws_ami", "name": "ubuntu",
+      "change": {"actions": ["read"], "before": null, "after": {}, "after_unknown": {"id": true}}
+    },
+    {
+      "mode": "managed", "type": "aws_eip", "name": "old",
+      "change": {"actions": ["delete"], "before": {"id": "eip-1"}, "after": null}
+    }
+  ],
+  "prior_state": {
+    "terraform_version": "1.5.7",
+    "values": {
+      "outputs": {"ip": {"sensitive": false, "value": "10.0.0.1"}},
+      "root_module": {
+        "resources": [{"mode": "managed", "type": "aws_vpc", "name": "main", "values": {"id": "vpc-1", "cidr_block": "10.0.0.0/16"}}]
+      }
+    }
+  }
+}`
+
+func TestReadShowPlan(t *testing.T) {
+	plan, err := ReadRawPlan(strings.NewReader(testShowPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]*terraform.InstanceDiff{
+		"root": {
+			"aws_db_instance.db": {
+				Destroy: true,
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"engine":   {Old: "postgres", New: "mysql", RequiresNew: true},
+					"id":       {Old: "db-1", NewComputed: true},
+					"password": {Old: "old", New: "new", Sensitive: true},
+				},
+			},
+			"data.aws_ami.ubuntu": {
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"id": {NewComputed: true},
+				},
+			},
+			"aws_eip.old": {
+				Destroy:    true,
+				Attributes: map[string]*terraform.ResourceAttrDiff{},
+			},
+		},
+		"root.app": {
+			"aws_instance.web.0": {
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"ami":       {New: "ami-1"},
+					"id":        {NewComputed: true, RequiresNew: true},
+					"tags.%":    {New: "1"},
+					"tags.Name": {New: "web"},
+				},
+			},
+		},
+	}
+	got := make(map[string]map[string]*terraform.InstanceDiff)
+	for _, m := range plan.Diff.Modules {
+		got[strings.Join(m.Path, ".")] = m.Resources
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := Marshal(got)
+		wantJSON, _ := Marshal(want)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+
+	if want := map[string]interface{}{"region": "us-east-1", "count": "2"}; !reflect.DeepEqual(plan.Vars, want) {
+		t.Errorf("Expected: %v\nActual: %v", want, plan.Vars)
+	}
+
+	vpc := plan.State.RootModule().Resources["aws_vpc.main"]
+	if vpc == nil || vpc.Primary.ID != "vpc-1" || vpc.Primary.Attributes["cidr_block"] != "10.0.0.0/16" {
+		t.Errorf("Expected aws_vpc.main in prior state, got %+v", vpc)
+	}
+	if ip := plan.State.RootModule().Outputs["ip"]; ip == nil || ip.Value != "10.0.0.1" {
+		t.Errorf("Expected output ip in prior state, got %+v", ip)
+	}
+}
+
+func TestReadShowPlanForEach(t *testing.T) {
+	for i, doc := range []string{
+		`{"format_version": "1.2", "resource_changes": [{"mode": "managed", "type": "aws_db_instance", "name": "db", "index": "eu.west",
+			"change": {"actions": ["create"], "before": null, "after": {}, "after_unknown": {"id": true}}}]}`,
+		`{"format_version": "1.2", "resource_changes": [], "prior_state": {"values": {"root_module": {"resources": [
+			{"mode": "managed", "type": "aws_db_instance", "name": "db", "index": "eu.west", "values": {"id": "db-1"}}]}}}}`,
+	} {
+		_, err := ReadRawPlan(strings.NewReader(doc))
+		if want := `aws_db_instance.db["eu.west"]: for_each instances cannot be represented as Terraform 0.7 resources`; err == nil || err.Error() != want {
+			t.Errorf("case %d: expected error %q, got %v", i, want, err)
+		}
+	}
+}
+
+func TestReadShowPlanSensitiveState(t *testing.T) {
+	plan, err := ReadRawPlan(strings.NewReader(`{
+  "format_version": "1.2",
+  "resource_changes": [],
+  "prior_state": {
+    "values": {
+      "root_module": {
+        "resources": [{
+          "mode": "managed", "type": "aws_db_instance", "name": "db",
+          "values": {"id": "db-1", "password": "hunter2", "tags": {"Name": "db", "token": "abc"}},
+          "sensitive_values": {"password": true, "tags": {"token": true}}
+        }]
+      }
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		opts               Options
+		password, tagToken string
+	}{
+		{Options{State: true}, SensitivePlaceholder, SensitivePlaceholder},
+		{Options{State: true, ShowSensitive: true}, "hunter2", "abc"},
+	} {
+		primary := ConvertPlan(plan, tc.opts).State.Modules[0].Resources[0].Primary
+		want := map[string]interface{}{
+			"id":         "db-1",
+			"password":   tc.password,
+			"tags.%":     "2",
+			"tags.Name":  "db",
+			"tags.token": tc.tagToken,
+		}
+		if !reflect.DeepEqual(primary.Attributes, want) {
+			t.Errorf("Expected: %v\nActual: %v", want, primary.Attributes)
+		}
+		if len(primary.Meta) != 0 {
+			t.Errorf("Expected no metadata, got %v", primary.Meta)
+		}
+
+		values := ConvertJSONPlan(plan, tc.opts).PriorState.Values.RootModule.Resources[0].Values
+		if got := values.(map[string]interface{})["password"]; got != tc.password {
+			t.Errorf("Expected password %q in the JSON prior state, got %v", tc.password, got)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// StateFile is the structured representation of a Terraform state file. Its
+// State has the same shape as the state included in a Plan.
+type StateFile struct {
+	FormatVersion string `json:"format_version"`
+	State         *State `json:"state"`
+}
+
+// State is the structured representation of a Terraform state.
+type State struct {
+	Version          int            `json:"version"`
+	TerraformVersion string         `json:"terraform_version"`
+	Serial           int64          `json:"serial"`
+	Lineage          string         `json:"lineage"`
+	Modules          []*StateModule `json:"modules"`
+}
+
+// StateModule is the state of a single module, identified by its path from
+// the root module.
+type StateModule struct {
+	Path      []string           `json:"path"`
+	Outputs   map[string]*Output `json:"outputs"`
+	Resources []*StateResource   `json:"resources"`
+	DependsOn []string           `json:"depends_on"`
+}
+
+// Output is the value of a single module output.
+type Output struct {
+	Sensitive bool        `json:"sensitive"`
+	Type      string      `json:"type"`
+	Value     interface{} `json:"value"`
+}
+
+// StateResource is the state of a single resource. Name is the key of the
+// resource within its module, such as "aws_instance.web.0".
+type StateResource struct {
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	Provider  string      `json:"provider"`
+	DependsOn []string    `json:"depends_on"`
+	Primary   *Instance   `json:"primary"`
+	Deposed   []*Instance `json:"deposed"`
+}
+
+// Instance is the state of a single instance of a resource.
+type Instance struct {
+	ID         string                 `json:"id"`
+	Tainted    bool                   `json:"tainted"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Meta       map[string]string      `json:"meta"`
+}
+
+// ReadState reads a Terraform state file of any version from r and converts
+// it.
+func ReadState(r io.Reader, opts Options) (*StateFile, error) {
+	state, err := ReadRawState(r)
+	if err != nil {
+		return nil, err
+	}
+	return &StateFile{
+		FormatVersion: FormatVersion,
+		State:         ConvertState(state, opts),
+	}, nil
+}
+
+// ReadRawState reads a Terraform state file of any version from r like
+// terraform.ReadState, but keeps the serial recorded in the file even if
+// terraform would increment it when upgrading or normalizing the state.
+func ReadRawState(r io.Reader) (*terraform.State, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := terraform.ReadState(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var serial struct {
+		Serial int64 `json:"serial"`
+	}
+	if err := json.Unmarshal(b, &serial); err != nil {
+		return nil, err
+	}
+	state.Serial = serial.Serial
+	return state, nil
+}
+
+// ConvertState converts a Terraform state.
+func ConvertState(state *terraform.State, opts Options) *State {
+	return convertState(state, nil, opts)
+}
+
+// convertState converts a Terraform state, redacting the attributes of each
+// instance that are marked in sensitive under its address. The state itself
+// does not record which attributes are sensitive.
+func convertState(state *terraform.State, sensitive map[string]map[string]bool, opts Options) *State {
+	out := &State{
+		Version:          state.Version,
+		TerraformVersion: state.TFVersion,
+		Serial:           state.Serial,
+		Lineage:          state.Lineage,
+		Modules:          []*StateModule{},
+	}
+	for _, v := range state.Modules {
+		out.Modules = append(out.Modules, convertModuleState(v, sensitive, opts))
+	}
+	sort.Sort(byStatePath(out.Modules))
+	return out
+}
+
+func convertModuleState(state *terraform.ModuleState, sensitive map[string]map[string]bool, opts Options) *StateModule {
+	out := &StateModule{
+		Path:      state.Path,
+		Outputs:   make(map[string]*Output),
+		Resources: []*StateResource{},
+		DependsOn: nonNil(state.Dependencies),
+	}
+	for k, v := range state.Outputs {
+		out.Outputs[k] = convertOutputState(v, opts)
+	}
+	for k, v := range state.Resources {
+		var attrs map[string]bool
+		if addr, err := InstanceAddress(state.Path, k); err == nil {
+			attrs = sensitive[addr.String()]
+		}
+		out.Resources = append(out.Resources, convertResourceState(k, v, attrs, opts))
+	}
+	sort.Sort(byStateName(out.Resources))
+	return out
+}
+
+func convertOutputState(state *terraform.OutputState, opts Options) *Output {
+	out := &Output{
+		Sensitive: state.Sensitive,
+		Type:      state.Type,
+		Value:     state.Value,
+	}
+	if state.Sensitive && !opts.ShowSensitive {
+		out.Value = SensitivePlaceholder
+	}
+	return out
+}
+
+func convertResourceState(name string, state *terraform.ResourceState, sensitive map[string]bool, opts Options) *StateResource {
+	out := &StateResource{
+		Name:      name,
+		Type:      state.Type,
+		Provider:  state.Provider,
+		DependsOn: nonNil(state.Dependencies),
+		Deposed:   []*Instance{},
+	}
+	if state.Primary != nil {
+		out.Primary = convertInstanceState(state.Primary, sensitive, opts)
+	}
+	for _, v := range state.Deposed {
+		out.Deposed = append(out.Deposed, convertInstanceState(v, sensitive, opts))
+	}
+	return out
+}
+
+func convertInstanceState(state *terraform.InstanceState, sensitive map[string]bool, opts Options) *Instance {
+	return &Instance{
+		ID:         state.ID,
+		Tainted:    state.Tainted,
+		Attributes: convertStateAttributes(state.Attributes, sensitive, opts),
+		Meta:       convertMeta(state.Meta),
+	}
+}
+
+// convertMeta returns meta without the keys that tfjson records for itself.
+func convertMeta(meta map[string]string) map[string]string {
+	if _, ok := meta[sensitiveMetaKey]; !ok {
+		return meta
+	}
+	out := make(map[string]string)
+	for k, v := range meta {
+		if k != sensitiveMetaKey {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// nonNil returns s, or an empty slice if s is nil, so that it is encoded as
+// an empty JSON array rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// byStatePath sorts module states by path.
+type byStatePath []*StateModule
+
+func (a byStatePath) Len() int           { return len(a) }
+func (a byStatePath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byStatePath) Less(i, j int) bool { return pathLess(a[i].Path, a[j].Path) }
+
+// byStateName sorts resource states by name.
+type byStateName []*StateResource
+
+func (a byStateName) Len() int           { return len(a) }
+func (a byStateName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byStateName) Less(i, j int) bool { return a[i].Name < a[j].Name }
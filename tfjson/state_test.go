@@ -0,0 +1,101 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testState = `{
+    "version": 3,
+    "terraform_version": "0.7.13",
+    "serial": 4,
+    "lineage": "5d056ee3-e7b0-4b08-ac44-7fa9b8ebf3dd",
+    "modules": [
+        {
+            "path": ["root"],
+            "outputs": {
+                "password": {"sensitive": true, "type": "string", "value": "hunter2"}
+            },
+            "resources": {
+                "aws_instance.web": {
+                    "type": "aws_instance",
+                    "depends_on": ["aws_vpc.main"],
+                    "primary": {
+                        "id": "i-1",
+                        "attributes": {"id": "i-1", "tags.%": "1", "tags.Name": "web"}
+                    }
+                }
+            }
+        }
+    ]
+}`
+
+func TestReadState(t *testing.T) {
+	got, err := ReadState(strings.NewReader(testState), Options{Expand: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &StateFile{
+		FormatVersion: FormatVersion,
+		State: &State{
+			Version:          3,
+			TerraformVersion: "0.7.13",
+			Serial:           4,
+			Lineage:          "5d056ee3-e7b0-4b08-ac44-7fa9b8ebf3dd",
+			Modules: []*StateModule{
+				{
+					Path: []string{"root"},
+					Outputs: map[string]*Output{
+						"password": {Sensitive: true, Type: "string", Value: SensitivePlaceholder},
+					},
+					Resources: []*StateResource{
+						{
+							Name:      "aws_instance.web",
+							Type:      "aws_instance",
+							DependsOn: []string{"aws_vpc.main"},
+							Primary: &Instance{
+								ID: "i-1",
+								Attributes: map[string]interface{}{
+									"id":   "i-1",
+									"tags": map[string]interface{}{"Name": "web"},
+								},
+								Meta: map[string]string{},
+							},
+							Deposed: []*Instance{},
+						},
+					},
+					DependsOn: []string{},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := Marshal(got)
+		wantJSON, _ := Marshal(want)
+		t.Errorf("Expected: %s\nActual: %s", wantJSON, gotJSON)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Summary counts the changes in a plan.
+type Summary struct {
+	// Empty is true if the plan makes no changes.
+	Empty bool `json:"empty"`
+	Counts
+	Modules []*ModuleSummary   `json:"modules"`
+	Types   map[string]*Counts `json:"types"`
+}
+
+// ModuleSummary counts the changes within a single module.
+type ModuleSummary struct {
+	Path   []string `json:"path"`
+	Action string   `json:"action"`
+	Counts
+}
+
+// Counts is the number of resource instances with each action. Data sources
+// read on apply are counted in Read rather than as changes, and data sources
+// that are only removed from the state are not counted.
+type Counts struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Replace int `json:"replace"`
+	Destroy int `json:"destroy"`
+	Read    int `json:"read"`
+}
+
+// add counts an instance with the given action.
+func (c *Counts) add(action string) {
+	switch action {
+	case "create":
+		c.Add++
+	case "update":
+		c.Change++
+	case "replace":
+		c.Replace++
+	case "destroy":
+		c.Destroy++
+	case "read":
+		c.Read++
+	}
+}
+
+// Summarize counts the changes in a diff, in total, per module and per
+// resource type.
+func Summarize(diff *terraform.Diff) *Summary {
+	out := &Summary{
+		Empty:   diff.Empty(),
+		Modules: []*ModuleSummary{},
+		Types:   make(map[string]*Counts),
+	}
+	for _, m := range diff.Modules {
+		ms := &ModuleSummary{
+			Path:   m.Path,
+			Action: moduleAction(m),
+		}
+		for k, v := range m.Resources {
+			t := instanceAction(keyMode(k), v)
+			ms.add(t)
+			out.add(t)
+
+			typ := resourceType(k)
+			if out.Types[typ] == nil {
+				out.Types[typ] = &Counts{}
+			}
+			out.Types[typ].add(t)
+		}
+		out.Modules = append(out.Modules, ms)
+	}
+	sort.Sort(bySummaryPath(out.Modules))
+	return out
+}
+
+// resourceType returns the resource type of the instance with the given key
+// in a module diff or state.
+func resourceType(key string) string {
+	if k, err := terraform.ParseResourceStateKey(key); err == nil {
+		return k.Type
+	}
+	return strings.SplitN(key, ".", 2)[0]
+}
+
+// bySummaryPath sorts module summaries by path.
+type bySummaryPath []*ModuleSummary
+
+func (a bySummaryPath) Len() int           { return len(a) }
+func (a bySummaryPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySummaryPath) Less(i, j int) bool { return pathLess(a[i].Path, a[j].Path) }
@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2016 Palantir Technologies
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tfjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestSummarize(t *testing.T) {
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			{
+				Path: []string{"root", "network"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_subnet.a": {Destroy: true},
+				},
+			},
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.InstanceDiff{
+					"aws_instance.web.0": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"id": {NewComputed: true, RequiresNew: true},
+						},
+					},
+					"aws_instance.web.1": {
+						Destroy: true,
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": {Old: "ami-1", New: "ami-2", RequiresNew: true},
+						},
+					},
+					"aws_db_instance.db": {
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"instance_class": {Old: "db.t2.micro", New: "db.t2.small"},
+						},
+					},
+					"aws_vpc.main": {},
+				},
+			},
+		},
+	}
+
+	want := &Summary{
+		Counts: Counts{Add: 1, Change: 1, Replace: 1, Destroy: 1},
+		Modules: []*ModuleSummary{
+			{Path: []string{"root"}, Action: "update", Counts: Counts{Add: 1, Change: 1, Replace: 1}},
+			{Path: []string{"root", "network"}, Action: "destroy", Counts: Counts{Destroy: 1}},
+		},
+		Types: map[string]*Counts{
+			"aws_instance":    {Add: 1, Replace: 1},
+			"aws_db_instance": {Change: 1},
+			"aws_vpc":         {},
+			"aws_subnet":      {Destroy: 1},
+		},
+	}
+	if got := Summarize(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected: %+v\nActual: %+v", want, got)
+	}
+
+	if got := Summarize(&terraform.Diff{}); !got.Empty {
+		t.Errorf("expected empty diff to be summarized as empty")
+	}
+}
@@ -23,11 +23,15 @@ SOFTWARE.
 package main
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
 )
 
 const mainTF = `
@@ -111,7 +115,7 @@ func Test(t *testing.T) {
 	mustRun(t, "terraform", "get", dir)
 	mustRun(t, "terraform", "plan", "-out="+planPath, dir)
 
-	j, err := tfjson(planPath)
+	j, err := convertFile(planPath, options{legacy: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -121,6 +125,137 @@ func Test(t *testing.T) {
 	}
 }
 
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		diff *terraform.InstanceDiff
+		want int
+	}{
+		{&terraform.InstanceDiff{}, exitNoChanges},
+		{&terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": {Old: "ami-1", New: "ami-2"},
+			},
+		}, exitChanges},
+		{&terraform.InstanceDiff{
+			Destroy: true,
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": {Old: "ami-1", New: "ami-2", RequiresNew: true},
+			},
+		}, exitDestroy},
+		{&terraform.InstanceDiff{Destroy: true}, exitDestroy},
+	} {
+		diff := &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": tc.diff,
+					},
+				},
+			},
+		}
+		if got := exitCode(diff); got != tc.want {
+			t.Errorf("%#v: expected %d, got %d", tc.diff, tc.want, got)
+		}
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	names := []string{"a.tfplan", "b.tfplan"}
+	docs := []interface{}{"a", "b"}
+	for _, tc := range []struct {
+		n             int
+		stream, keyed bool
+		want          []interface{}
+	}{
+		{1, false, false, []interface{}{"a"}},
+		{1, false, true, []interface{}{map[string]interface{}{"a.tfplan": "a"}}},
+		{2, false, false, []interface{}{map[string]interface{}{"a.tfplan": "a", "b.tfplan": "b"}}},
+		{2, false, true, []interface{}{map[string]interface{}{"a.tfplan": "a", "b.tfplan": "b"}}},
+		{1, true, false, []interface{}{"a"}},
+		{2, true, false, []interface{}{"a", "b"}},
+	} {
+		if got := documents(names[:tc.n], docs[:tc.n], tc.stream, tc.keyed); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d plans, stream %t, keyed %t: expected %v, got %v", tc.n, tc.stream, tc.keyed, tc.want, got)
+		}
+	}
+}
+
+func TestCheckModes(t *testing.T) {
+	for _, tc := range []struct {
+		set  []string
+		want string
+	}{
+		{[]string{"state", "config", "detailed"}, ""},
+		{[]string{"flat", "expand", "show-sensitive"}, ""},
+		{[]string{"show-json", "state", "variables", "redact-vars"}, ""},
+		{[]string{"legacy", "show-sensitive", "stream", "filter"}, ""},
+		{[]string{"flat", "group"}, "-flat and -group cannot be used together"},
+		{[]string{"summary", "legacy", "show-json"}, "-summary and -legacy and -show-json cannot be used together"},
+		{[]string{"group", "state"}, "-state does not apply to -group"},
+		{[]string{"flat", "config"}, "-config does not apply to -flat"},
+		{[]string{"summary", "detailed"}, "-detailed does not apply to -summary"},
+		{[]string{"legacy", "expand"}, "-expand does not apply to -legacy"},
+		{[]string{"show-json", "targets"}, "-targets does not apply to -show-json"},
+	} {
+		set := make(map[string]bool)
+		for _, name := range tc.set {
+			set[name] = true
+		}
+		got := ""
+		if err := checkModes(set); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%v: expected %q, got %q", tc.set, tc.want, got)
+		}
+	}
+}
+
+func TestReadPlanStdin(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.web": {Destroy: true},
+					},
+				},
+			},
+		},
+	}
+
+	f, err := ioutil.TempFile("", "tfjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := terraform.WritePlan(plan, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	got, err := readPlan("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got.Diff.ModuleByPath([]string{"root"}); d == nil || !d.Resources["aws_instance.web"].Destroy {
+		t.Errorf("Expected the plan written to standard input, got %v", got.Diff)
+	}
+
+	if _, err := readPlan(f.Name() + ".missing"); err == nil {
+		t.Error("Expected an error for a missing plan file")
+	}
+}
+
 func mustRun(t *testing.T, name string, arg ...string) {
 	if _, err := exec.Command(name, arg...).Output(); err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {